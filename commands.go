@@ -0,0 +1,274 @@
+// commands.go
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"chaos/v2/qhash"
+)
+
+// commands maps subcommand names to their handlers. Invocations without a
+// subcommand use the flag interface in main.
+var commands = map[string]func(args []string) error{
+	"mac":        runMAC,
+	"verify-mac": runVerifyMAC,
+	"derive":     runDerive,
+	"rand":       runRand,
+	"encrypt":    runEncrypt,
+	"decrypt":    runDecrypt,
+	"selftest":   runSelftest,
+	"stats":      runStats,
+}
+
+// inputFlags registers the -input/-file pair shared by subcommands.
+func inputFlags(fs *flag.FlagSet) (in, file *string) {
+	in = fs.String("input", "", "Input data")
+	file = fs.String("file", "", "File path to read (- for stdin)")
+	return in, file
+}
+
+// macFlags parses the flags shared by mac and verify-mac.
+type macFlags struct {
+	fs   *flag.FlagSet
+	key  *string
+	size *int
+	in   *string
+	file *string
+}
+
+func newMACFlags(name string) *macFlags {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	m := &macFlags{
+		fs:   fs,
+		key:  fs.String("key", "", "MAC key (required)"),
+		size: fs.Int("size", 256, "Hash size: 256, 384, 512, or 1024 bits"),
+	}
+	m.in, m.file = inputFlags(fs)
+	return m
+}
+
+// tag computes the MAC of the selected input.
+func (m *macFlags) tag() ([]byte, error) {
+	if *m.key == "" {
+		return nil, fmt.Errorf("-key is required")
+	}
+	if *m.in == "" && *m.file == "" {
+		return nil, fmt.Errorf("-input or -file is required")
+	}
+
+	mac, err := qhash.NewMAC([]byte(*m.key), *m.size)
+	if err != nil {
+		return nil, err
+	}
+	r, err := openInput(*m.file, *m.in)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if _, err := io.Copy(mac, r); err != nil {
+		return nil, fmt.Errorf("read failed: %w", err)
+	}
+	return mac.Sum(nil), nil
+}
+
+// runMAC prints the QHASH-HMAC tag of the input.
+func runMAC(args []string) error {
+	m := newMACFlags("mac")
+	m.fs.Parse(args)
+
+	tag, err := m.tag()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("QHASH-MAC-%d\nHEX: %x\nB64: %s\n",
+		*m.size, tag, base64.StdEncoding.EncodeToString(tag))
+	return nil
+}
+
+// runVerifyMAC checks a tag (hex or base64) against the input and exits
+// non-zero on mismatch.
+func runVerifyMAC(args []string) error {
+	m := newMACFlags("verify-mac")
+	tagArg := m.fs.String("tag", "", "Expected tag, hex or base64 (required)")
+	m.fs.Parse(args)
+
+	expected, err := hex.DecodeString(*tagArg)
+	if err != nil {
+		expected, err = base64.StdEncoding.DecodeString(*tagArg)
+		if err != nil {
+			return fmt.Errorf("tag is neither hex nor base64")
+		}
+	}
+
+	tag, err := m.tag()
+	if err != nil {
+		return err
+	}
+
+	ok := qhash.Equal(tag, expected)
+	fmt.Println("MAC OK:", ok)
+	if !ok {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// encodeOutput renders b as hex or base64 for the -format flag.
+func encodeOutput(b []byte, format string) (string, error) {
+	switch format {
+	case "hex":
+		return hex.EncodeToString(b), nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString(b), nil
+	}
+	return "", fmt.Errorf("unknown format %q: use hex or base64", format)
+}
+
+// runDerive prints a key derived from a secret or password.
+func runDerive(args []string) error {
+	fs := flag.NewFlagSet("derive", flag.ExitOnError)
+	secret := fs.String("secret", "", "Secret or password")
+	file := fs.String("file", "", "Read the secret from a file (- for stdin)")
+	salt := fs.String("salt", "", "Salt (required with -password)")
+	info := fs.String("info", "", "Context string binding the key to its use")
+	length := fs.Int("length", 32, "Key length in bytes")
+	password := fs.Bool("password", false, "Treat the secret as a password and apply hardened costs")
+	format := fs.String("format", "hex", "Output format: hex or base64")
+	fs.Parse(args)
+
+	if *secret == "" && *file == "" {
+		return fmt.Errorf("-secret or -file is required")
+	}
+	r, err := openInput(*file, *secret)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	material, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("read failed: %w", err)
+	}
+
+	var key []byte
+	if *password {
+		key, err = qhash.DerivePasswordKey(material, []byte(*salt), []byte(*info),
+			*length, qhash.DefaultPasswordParams)
+	} else {
+		key, err = qhash.DeriveKey(material, []byte(*salt), []byte(*info), *length)
+	}
+	if err != nil {
+		return err
+	}
+
+	out, err := encodeOutput(key, *format)
+	if err != nil {
+		return err
+	}
+	fmt.Println(out)
+	return nil
+}
+
+// runRand writes bytes from a qhash.Stream. Without -seed the stream is
+// seeded from crypto/rand.
+func runRand(args []string) error {
+	fs := flag.NewFlagSet("rand", flag.ExitOnError)
+	n := fs.Int64("n", 32, "Number of bytes")
+	seed := fs.String("seed", "", "Seed for a reproducible stream")
+	format := fs.String("format", "hex", "Output format: hex, base64, or raw")
+	fs.Parse(args)
+
+	if *n < 0 {
+		return fmt.Errorf("-n must not be negative")
+	}
+	s := []byte(*seed)
+	if len(s) == 0 {
+		s = make([]byte, 32)
+		if _, err := rand.Read(s); err != nil {
+			return fmt.Errorf("seeding failed: %w", err)
+		}
+	}
+	stream, err := qhash.NewStream(s)
+	if err != nil {
+		return err
+	}
+
+	var w io.WriteCloser
+	switch *format {
+	case "hex":
+		w = nopCloser{hex.NewEncoder(os.Stdout)}
+	case "base64":
+		w = base64.NewEncoder(base64.StdEncoding, os.Stdout)
+	case "raw":
+		w = nopCloser{os.Stdout}
+	default:
+		return fmt.Errorf("unknown format %q: use hex, base64, or raw", *format)
+	}
+	if _, err := io.CopyN(w, stream, *n); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if *format != "raw" {
+		fmt.Println()
+	}
+	return nil
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// runSelftest checks the built-in known-answer vectors. -regenerate prints
+// the vector file with expected values recomputed by this build instead.
+func runSelftest(args []string) error {
+	fs := flag.NewFlagSet("selftest", flag.ExitOnError)
+	verbose := fs.Bool("v", false, "Report every vector")
+	regenerate := fs.Bool("regenerate", false, "Print the vector file with recomputed expected values")
+	fs.Parse(args)
+
+	set, err := qhash.KnownAnswers()
+	if err != nil {
+		return err
+	}
+
+	if *regenerate {
+		for i, v := range set.Vectors {
+			if set.Vectors[i], err = v.Compute(); err != nil {
+				return fmt.Errorf("%s: %w", v.Name, err)
+			}
+		}
+		set.AlgorithmVersion = qhash.AlgorithmVersion
+		j, err := json.MarshalIndent(set, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(j))
+		return nil
+	}
+
+	failed := 0
+	for _, v := range set.Vectors {
+		err := v.Check()
+		switch {
+		case err != nil:
+			failed++
+			fmt.Println("FAIL", err)
+		case *verbose:
+			fmt.Println("ok  ", v.Name)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d vectors failed (vector set %d)", failed, len(set.Vectors), set.Version)
+	}
+	fmt.Printf("Selftest OK: %d vectors (vector set %d, algorithm %s)\n",
+		len(set.Vectors), set.Version, set.AlgorithmVersion)
+	return nil
+}
@@ -0,0 +1,308 @@
+// encrypt.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"chaos/v2/qhash"
+)
+
+// Encrypted files start with a header that is authenticated as additional
+// data of every chunk:
+//
+//	magic "QHENC" | version | kdf | time, memory, parallelism (uint32 BE)
+//	| salt (16) | nonce prefix (11)
+//
+// The body is a sequence of chunks of encChunkSize plaintext bytes sealed
+// with the experimental qhash AEAD. Chunk i uses the nonce
+// prefix || uint32 BE i || last, where last is 1 for the final chunk only,
+// so truncation and reordering are detected.
+const (
+	encMagic      = "QHENC"
+	encVersion    = 2 // 1 used an unwhitened keystream
+	encChunkSize  = 1 << 20
+	encSaltSize   = qhash.MinKDFSaltSize
+	encPrefixSize = qhash.AEADNonceSize - 5
+	encHeaderSize = len(encMagic) + 2 + 12 + encSaltSize + encPrefixSize
+	encKeyInfo    = "chaos encrypt v2"
+	encMaxMemory  = 64 << 10 // KiB a file may request unless -maxmemory raises it
+)
+
+// Key derivation functions recorded in the header.
+const (
+	kdfKey      = 0 // qhash.DeriveKey
+	kdfPassword = 1 // qhash.DerivePasswordKey with the recorded costs
+)
+
+type encHeader struct {
+	kdf    byte
+	params qhash.PasswordParams
+	salt   []byte
+	prefix []byte
+}
+
+func (h *encHeader) marshal() []byte {
+	b := append([]byte(encMagic), encVersion, h.kdf)
+	b = binary.BigEndian.AppendUint32(b, uint32(h.params.TimeCost))
+	b = binary.BigEndian.AppendUint32(b, uint32(h.params.MemoryCost))
+	b = binary.BigEndian.AppendUint32(b, uint32(h.params.Parallelism))
+	b = append(b, h.salt...)
+	return append(b, h.prefix...)
+}
+
+// parseEncHeader parses an unauthenticated header. Password costs are
+// bounded before key derivation spends them, the memory cost by maxMemory
+// KiB.
+func parseEncHeader(b []byte, maxMemory int) (*encHeader, error) {
+	if len(b) != encHeaderSize || !bytes.HasPrefix(b, []byte(encMagic)) {
+		return nil, fmt.Errorf("not an encrypted file")
+	}
+	b = b[len(encMagic):]
+	if b[0] != encVersion {
+		return nil, fmt.Errorf("unsupported format version: %d", b[0])
+	}
+	h := &encHeader{kdf: b[1]}
+	if h.kdf != kdfKey && h.kdf != kdfPassword {
+		return nil, fmt.Errorf("unknown key derivation: %d", h.kdf)
+	}
+	b = b[2:]
+	h.params = qhash.PasswordParams{
+		HashSize:    256,
+		TimeCost:    int(binary.BigEndian.Uint32(b[0:])),
+		MemoryCost:  int(binary.BigEndian.Uint32(b[4:])),
+		Parallelism: int(binary.BigEndian.Uint32(b[8:])),
+	}
+	h.salt = b[12 : 12+encSaltSize]
+	h.prefix = b[12+encSaltSize:]
+	if err := h.checkCosts(maxMemory); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// checkCosts rejects password costs outside the hasher's limits or above
+// maxMemory KiB. Key-derived files carry no costs.
+func (h *encHeader) checkCosts(maxMemory int) error {
+	p := h.params
+	if h.kdf == kdfKey {
+		if p.TimeCost != 0 || p.MemoryCost != 0 || p.Parallelism != 0 {
+			return fmt.Errorf("unexpected costs for key derivation")
+		}
+		return nil
+	}
+	switch {
+	case p.TimeCost < 1 || p.TimeCost > qhash.MaxTimeCost:
+		return fmt.Errorf("time cost out of range: %d", p.TimeCost)
+	case p.Parallelism < 1 || p.Parallelism > qhash.MaxParallelism:
+		return fmt.Errorf("parallelism out of range: %d", p.Parallelism)
+	case p.MemoryCost < p.Parallelism || p.MemoryCost > qhash.MaxMemoryHardness:
+		return fmt.Errorf("memory cost out of range: %d KiB", p.MemoryCost)
+	case p.MemoryCost > maxMemory:
+		return fmt.Errorf("memory cost %d KiB exceeds limit of %d KiB (raise -maxmemory)",
+			p.MemoryCost, maxMemory)
+	}
+	return nil
+}
+
+// aead derives the file key from secret and returns the cipher.
+func (h *encHeader) aead(secret []byte) (cipher.AEAD, error) {
+	var key []byte
+	var err error
+	if h.kdf == kdfPassword {
+		key, err = qhash.DerivePasswordKey(secret, h.salt, []byte(encKeyInfo),
+			qhash.AEADKeySize, h.params)
+	} else {
+		key, err = qhash.DeriveKey(secret, h.salt, []byte(encKeyInfo), qhash.AEADKeySize)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key derivation failed: %w", err)
+	}
+	return qhash.NewAEAD(key)
+}
+
+func (h *encHeader) nonce(i uint32, last bool) []byte {
+	n := binary.BigEndian.AppendUint32(append([]byte{}, h.prefix...), i)
+	if last {
+		return append(n, 1)
+	}
+	return append(n, 0)
+}
+
+// cryptFlags parses the flags shared by encrypt and decrypt.
+type cryptFlags struct {
+	fs       *flag.FlagSet
+	key      *string
+	password *bool
+	in       *string
+	file     *string
+	out      *string
+}
+
+func newCryptFlags(name string) *cryptFlags {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	c := &cryptFlags{
+		fs:       fs,
+		key:      fs.String("key", "", "Secret key or password (required)"),
+		password: fs.Bool("password", false, "Treat -key as a password and apply hardened costs"),
+		out:      fs.String("out", "-", "Output file (- for stdout)"),
+	}
+	c.in, c.file = inputFlags(fs)
+	return c
+}
+
+// run opens the input and output and calls crypt on them. A partially
+// written output file is removed on failure.
+func (c *cryptFlags) run(args []string, crypt func(r io.Reader, w io.Writer) error) error {
+	c.fs.Parse(args)
+	if *c.key == "" {
+		return fmt.Errorf("-key is required")
+	}
+	if *c.in == "" && *c.file == "" {
+		return fmt.Errorf("-input or -file is required")
+	}
+
+	r, err := openInput(*c.file, *c.in)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if *c.out == "-" {
+		w := bufio.NewWriter(os.Stdout)
+		if err := crypt(r, w); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+
+	f, err := os.Create(*c.out)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	err = crypt(r, w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(*c.out)
+	}
+	return err
+}
+
+// runEncrypt encrypts the input with the experimental qhash AEAD.
+func runEncrypt(args []string) error {
+	c := newCryptFlags("encrypt")
+	return c.run(args, func(r io.Reader, w io.Writer) error {
+		h := &encHeader{
+			kdf:    kdfKey,
+			salt:   make([]byte, encSaltSize),
+			prefix: make([]byte, encPrefixSize),
+		}
+		if *c.password {
+			h.kdf, h.params = kdfPassword, qhash.DefaultPasswordParams
+		}
+		if _, err := rand.Read(h.salt); err != nil {
+			return err
+		}
+		if _, err := rand.Read(h.prefix); err != nil {
+			return err
+		}
+		aead, err := h.aead([]byte(*c.key))
+		if err != nil {
+			return err
+		}
+
+		header := h.marshal()
+		if _, err := w.Write(header); err != nil {
+			return err
+		}
+		return sealChunks(aead, h, header, bufio.NewReaderSize(r, encChunkSize), w, encChunkSize)
+	})
+}
+
+// sealChunks writes r as sealed chunks of size plaintext bytes.
+func sealChunks(aead cipher.AEAD, h *encHeader, header []byte, r *bufio.Reader, w io.Writer, size int) error {
+	buf := make([]byte, size, size+aead.Overhead())
+	for i := uint32(0); ; i++ {
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("read failed: %w", err)
+		}
+		last := err != nil
+		if !last {
+			_, perr := r.Peek(1)
+			last = perr == io.EOF
+		}
+		if i == ^uint32(0) && !last {
+			return fmt.Errorf("input too large")
+		}
+
+		if _, err := w.Write(aead.Seal(buf[:0], h.nonce(i, last), buf[:n], header)); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// runDecrypt decrypts a file written by runEncrypt.
+func runDecrypt(args []string) error {
+	c := newCryptFlags("decrypt")
+	maxMemory := c.fs.Int("maxmemory", encMaxMemory, "Largest password memory cost in KiB a file may request")
+	return c.run(args, func(r io.Reader, w io.Writer) error {
+		header := make([]byte, encHeaderSize)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return fmt.Errorf("not an encrypted file")
+		}
+		h, err := parseEncHeader(header, *maxMemory)
+		if err != nil {
+			return err
+		}
+		aead, err := h.aead([]byte(*c.key))
+		if err != nil {
+			return err
+		}
+		return openChunks(aead, h, header, bufio.NewReaderSize(r, encChunkSize), w, encChunkSize)
+	})
+}
+
+// openChunks writes the plaintext of the chunks in r, sealed with size
+// plaintext bytes each.
+func openChunks(aead cipher.AEAD, h *encHeader, header []byte, r *bufio.Reader, w io.Writer, size int) error {
+	buf := make([]byte, size+aead.Overhead())
+	for i := uint32(0); ; i++ {
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("read failed: %w", err)
+		}
+		last := err != nil
+		if !last {
+			_, perr := r.Peek(1)
+			last = perr == io.EOF
+		}
+
+		plain, err := aead.Open(buf[:0], h.nonce(i, last), buf[:n], header)
+		if err != nil {
+			return fmt.Errorf("chunk %d: %w", i, err)
+		}
+		if _, err := w.Write(plain); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
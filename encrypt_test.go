@@ -0,0 +1,127 @@
+// encrypt_test.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"testing"
+
+	"chaos/v2/qhash"
+)
+
+// testChunkSize keeps multi-chunk files small enough to seal quickly.
+const testChunkSize = 64
+
+func newTestEncryption(t *testing.T) (cipher.AEAD, *encHeader, []byte) {
+	t.Helper()
+	h := &encHeader{
+		kdf:    kdfKey,
+		salt:   bytes.Repeat([]byte{1}, encSaltSize),
+		prefix: bytes.Repeat([]byte{2}, encPrefixSize),
+	}
+	aead, err := h.aead([]byte("secret key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead, h, h.marshal()
+}
+
+func TestChunksRoundTrip(t *testing.T) {
+	aead, h, header := newTestEncryption(t)
+	for _, n := range []int{0, testChunkSize, 2*testChunkSize + 10} {
+		plain := bytes.Repeat([]byte{'x'}, n)
+		var sealed, opened bytes.Buffer
+		if err := sealChunks(aead, h, header, bufio.NewReader(bytes.NewReader(plain)), &sealed, testChunkSize); err != nil {
+			t.Fatal(err)
+		}
+		chunks := max(1, (n+testChunkSize-1)/testChunkSize)
+		if want := n + chunks*aead.Overhead(); sealed.Len() != want {
+			t.Errorf("%d bytes sealed to %d, want %d", n, sealed.Len(), want)
+		}
+		if err := openChunks(aead, h, header, bufio.NewReader(&sealed), &opened, testChunkSize); err != nil {
+			t.Fatalf("%d bytes: %v", n, err)
+		}
+		if !bytes.Equal(opened.Bytes(), plain) {
+			t.Errorf("%d bytes opened to %d bytes", n, opened.Len())
+		}
+	}
+}
+
+func TestChunksRejectTampering(t *testing.T) {
+	aead, h, header := newTestEncryption(t)
+	plain := bytes.Repeat([]byte("chunk"), 30) // three chunks, the last one short
+	var buf bytes.Buffer
+	if err := sealChunks(aead, h, header, bufio.NewReader(bytes.NewReader(plain)), &buf, testChunkSize); err != nil {
+		t.Fatal(err)
+	}
+	sealed := buf.Bytes()
+	full := testChunkSize + aead.Overhead()
+
+	flipped := append([]byte(nil), sealed...)
+	flipped[full+5] ^= 1
+	reordered := append(append(append([]byte(nil),
+		sealed[full:2*full]...), sealed[:full]...), sealed[2*full:]...)
+	otherHeader := append([]byte(nil), header...)
+	otherHeader[len(otherHeader)-1] ^= 1
+
+	tests := []struct {
+		name   string
+		header []byte
+		sealed []byte
+	}{
+		{"flipped byte", header, flipped},
+		{"last chunk dropped", header, sealed[:2*full]},
+		{"last chunk truncated", header, sealed[:len(sealed)-1]},
+		{"chunks reordered", header, reordered},
+		{"header changed", otherHeader, sealed},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := openChunks(aead, h, tt.header, bufio.NewReader(bytes.NewReader(tt.sealed)), &out, testChunkSize); err == nil {
+			t.Errorf("%s: opened to %d bytes", tt.name, out.Len())
+		}
+	}
+}
+
+func TestParseEncHeaderCosts(t *testing.T) {
+	password := func(time, memory, parallelism int) []byte {
+		return (&encHeader{
+			kdf:    kdfPassword,
+			params: qhash.PasswordParams{TimeCost: time, MemoryCost: memory, Parallelism: parallelism},
+			salt:   make([]byte, encSaltSize),
+			prefix: make([]byte, encPrefixSize),
+		}).marshal()
+	}
+	if _, err := parseEncHeader(password(3, encMaxMemory, 4), encMaxMemory); err != nil {
+		t.Errorf("costs at the limit rejected: %v", err)
+	}
+	if _, err := parseEncHeader(password(3, 2*encMaxMemory, 4), 2*encMaxMemory); err != nil {
+		t.Errorf("costs under a raised limit rejected: %v", err)
+	}
+
+	keyed := (&encHeader{
+		kdf:    kdfKey,
+		params: qhash.PasswordParams{TimeCost: 1},
+		salt:   make([]byte, encSaltSize),
+		prefix: make([]byte, encPrefixSize),
+	}).marshal()
+	tests := []struct {
+		name   string
+		header []byte
+	}{
+		{"memory over limit", password(3, encMaxMemory+1, 4)},
+		{"memory over hasher limit", password(3, qhash.MaxMemoryHardness+1, 4)},
+		{"memory below parallelism", password(3, 2, 4)},
+		{"zero time", password(0, 1024, 1)},
+		{"time over limit", password(qhash.MaxTimeCost+1, 1024, 1)},
+		{"zero parallelism", password(3, 1024, 0)},
+		{"parallelism over limit", password(3, 1024, qhash.MaxParallelism+1)},
+		{"costs with key derivation", keyed},
+	}
+	for _, tt := range tests {
+		if _, err := parseEncHeader(tt.header, encMaxMemory); err == nil {
+			t.Errorf("%s: accepted", tt.name)
+		}
+	}
+}
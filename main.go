@@ -2,13 +2,16 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"math"
 	"os"
+	"strings"
 	"time"
 
 	"chaos/v2/qhash"
@@ -17,18 +20,40 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		if run, ok := commands[os.Args[1]]; ok {
+			if err := run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	graphics := flag.Bool("graphics", false, "Enable graphics visualization")
 	genH := flag.Bool("genhardened", false, "Generate hardened hash")
 	gen := flag.Bool("genhash", false, "Generate simple hash")
 	in := flag.String("input", "", "Input data to hash")
-	file := flag.String("file", "", "File path to hash")
+	file := flag.String("file", "", "File path to hash (- for stdin)")
 	vr := flag.String("verify", "", "Data to verify against hash")
-	verifyFile := flag.String("verifyfile", "", "File to verify against hash")
-	hjson := flag.String("hardenedhash", "", "Hardened hash JSON (base64 or raw)")
+	verifyFile := flag.String("verifyfile", "", "File to verify against hash (- for stdin)")
+	hjson := flag.String("hardenedhash", "", "Hardened hash: $qhash$ string, or JSON (base64 or raw)")
 	hash64 := flag.String("hash", "", "Hash to verify against (base64)")
 	hashSize := flag.Int("size", 256, "Hash size: 256, 384, 512, or 1024 bits")
+	key := flag.String("key", "", "Key for deterministic keyed hashing (genhash/hash)")
+	untimed := flag.Bool("untimed", false, "Do not bind hardened hashes to the current hour")
+	integrator := flag.String("integrator", qhash.DefaultIntegrator, "Stage integrator: euler, rk4, or dopri5")
+	system := flag.String("system", qhash.DefaultSystem, "Chaotic system: lorenz, rossler, chen, lu, thomas, or hyperlorenz")
+	engine := flag.String("engine", qhash.EngineBigFloat, "Trajectory engine: bigfloat, fixed96, or float64")
+	memory := flag.Int("memory", qhash.DefaultMemoryHardness, "Memory-hard buffer size in KiB (0 disables)")
+	lanes := flag.Int("lanes", 1, "Independent stage lanes computed concurrently")
+	configPath := flag.String("config", "", "JSON stage configuration file (overrides the built-in stages)")
+	progressive := flag.Bool("progressive", false, "Verify hardened hashes stage by stage and stop at the first mismatch (not constant time)")
 	flag.Parse()
 
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
 	// Validate hash size
 	validSizes := map[int]bool{256: true, 384: true, 512: true, 1024: true}
 	if !validSizes[*hashSize] {
@@ -36,33 +61,75 @@ func main() {
 		os.Exit(1)
 	}
 
-	hasher, err := qhash.NewHardenedLorenzHasher(*hashSize)
+	opts := []qhash.Option{
+		qhash.WithMemoryHardness(*memory),
+		qhash.WithLanes(*lanes),
+		qhash.WithEngine(*engine),
+	}
+	// Only explicit flags override the stages, so a config file keeps its own
+	if set["integrator"] {
+		opts = append(opts, qhash.WithIntegrator(*integrator))
+	}
+	if set["system"] {
+		opts = append(opts, qhash.WithSystem(*system))
+	}
+	if *untimed {
+		opts = append(opts, qhash.WithoutTimeBinding())
+	}
+	if *key != "" {
+		opts = append(opts, qhash.WithKey([]byte(*key)))
+	}
+	// Plain hashes are content fingerprints; only hardened ones keep the floor
+	if !*genH && *hjson == "" {
+		opts = append(opts, qhash.WithoutMinComputeTime())
+	}
+
+	hasher, err := newHasher(*configPath, *hashSize, opts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to initialize hasher: %v\n", err)
 		os.Exit(1)
 	}
+	*hashSize = hasher.GetHashSize()
+
+	if (*genH || *gen) && *file == "" && *in == "" {
+		fmt.Fprintf(os.Stderr, "Error: input or file required for hash generation\n")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	// Plain hashes of files and stdin take the library's streaming path, so
+	// they match qhash.New and HashReader on the same bytes.
+	if *gen && !*genH {
+		h, n, err := plainHash(hasher, *file, *in)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Hashing failed: %v\n", err)
+			os.Exit(1)
+		}
+		if *file != "" {
+			fmt.Printf("Loaded file: %s (%d bytes)\n", *file, n)
+		}
+		fmt.Printf("QHASH-%d\nHEX: %x\nB64: %s\n",
+			*hashSize, h, base64.StdEncoding.EncodeToString(h),
+		)
+		return
+	}
 
-	// Input validation and data loading
+	// Hardened hashes of files and stdin consume the absorbed state; the
+	// input string is hashed as given.
 	var inputData []byte
-	if *file != "" {
-		data, err := os.ReadFile(*file)
+	if *genH && *file != "" {
+		state, n, err := absorbFile(*file)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to read file %s: %v\n", *file, err)
 			os.Exit(1)
 		}
-		inputData = data
-		fmt.Printf("Loaded file: %s (%d bytes)\n", *file, len(data))
-	} else if *in != "" {
+		fmt.Printf("Loaded file: %s (%d bytes)\n", *file, n)
+		inputData = state
+	} else if *genH {
 		inputData = []byte(*in)
 	}
 
-	if (*genH || *gen) && len(inputData) == 0 {
-		fmt.Fprintf(os.Stderr, "Error: input or file required for hash generation\n")
-		flag.Usage()
-		os.Exit(1)
-	}
-
-	if *genH && len(inputData) > 0 {
+	if *genH {
 		out, err := hasher.HashWithHardening(inputData)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Hashing failed: %v\n", err)
@@ -73,48 +140,46 @@ func main() {
 			fmt.Fprintf(os.Stderr, "JSON encoding failed: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Printf("QHASH-%d\nHEX: %x\nMEM: %dKB\nTIME: %dms\nJSON:\n%s\nB64:\n%s\n",
-			*hashSize, out.Hash, out.MemoryUsed, out.ComputeTime/1e6,
+		phc, err := out.EncodePHC()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "PHC encoding failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("QHASH-%d\nHEX: %x\nMEM: %dKB\nTIME: %dms\nPHC: %s\nJSON:\n%s\nB64:\n%s\n",
+			*hashSize, out.Hash, out.MemoryUsed, out.ComputeTime/1e6, phc,
 			j, base64.StdEncoding.EncodeToString(j),
 		)
 		return
 	}
 
-	if *gen && len(inputData) > 0 {
-		h, err := hasher.Hash(inputData)
+	// Verification logic
+	if *hjson == "" && *hash64 != "" && (*verifyFile != "" || *vr != "") {
+		got, _, err := plainHash(hasher, *verifyFile, *vr)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Hashing failed: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Verification failed: hashing error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := verifyLegacyHash(got, *hash64); err != nil {
+			fmt.Fprintf(os.Stderr, "Verification failed: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Printf("QHASH-%d\nHEX: %x\nB64: %s\n",
-			*hashSize, h, base64.StdEncoding.EncodeToString(h),
-		)
 		return
 	}
 
-	// Verification logic
 	var verifyData []byte
 	if *verifyFile != "" {
-		data, err := os.ReadFile(*verifyFile)
+		state, _, err := absorbFile(*verifyFile)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to read verify file %s: %v\n", *verifyFile, err)
 			os.Exit(1)
 		}
-		verifyData = data
+		verifyData = state
 	} else if *vr != "" {
 		verifyData = []byte(*vr)
 	}
 
 	if len(verifyData) > 0 && *hjson != "" {
-		if err := verifyHardenedHash(verifyData, *hjson, hasher); err != nil {
-			fmt.Fprintf(os.Stderr, "Verification failed: %v\n", err)
-			os.Exit(1)
-		}
-		return
-	}
-
-	if len(verifyData) > 0 && *hash64 != "" {
-		if err := verifyLegacyHash(verifyData, *hash64, hasher); err != nil {
+		if err := verifyHardenedHash(verifyData, *hjson, hasher, *progressive); err != nil {
 			fmt.Fprintf(os.Stderr, "Verification failed: %v\n", err)
 			os.Exit(1)
 		}
@@ -132,19 +197,101 @@ func main() {
 	flag.Usage()
 }
 
-func verifyHardenedHash(data []byte, hjson string, hasher *qhash.HardenedLorenzHasher) error {
-	raw, err := base64.StdEncoding.DecodeString(hjson)
+// progressThreshold is the input size after which progress is reported.
+const progressThreshold = 16 << 20
+
+// newHasher builds the hasher from the stage config at path, or from the
+// built-in stages when path is empty. A config without hash_size uses size.
+func newHasher(path string, size int, opts []qhash.Option) (*qhash.HardenedLorenzHasher, error) {
+	if path == "" {
+		return qhash.NewHardenedLorenzHasher(size, opts...)
+	}
+	cfg, err := qhash.LoadStageConfig(path)
 	if err != nil {
-		// Try as raw JSON if base64 decode fails
-		raw = []byte(hjson)
+		return nil, err
+	}
+	if cfg.HashSize == 0 {
+		cfg.HashSize = size
 	}
+	return qhash.NewHasherFromConfig(cfg, opts...)
+}
 
-	var stored qhash.HardenedSaltedHash
-	if err := json.Unmarshal(raw, &stored); err != nil {
-		return fmt.Errorf("JSON decode error: %w", err)
+// openInput returns a reader over path ("-" for stdin) or, if path is
+// empty, the literal input string.
+func openInput(path, literal string) (io.ReadCloser, error) {
+	switch path {
+	case "":
+		return io.NopCloser(strings.NewReader(literal)), nil
+	case "-":
+		return io.NopCloser(os.Stdin), nil
 	}
+	return os.Open(path)
+}
 
-	ok, err := hasher.VerifyHardenedHash(data, &stored)
+// absorbFile streams path ("-" for stdin) through the qhash absorber and
+// returns the compressed state.
+func absorbFile(path string) ([]byte, int64, error) {
+	r, err := openInput(path, "")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer r.Close()
+
+	progress, done := absorbProgress()
+	state, n, err := qhash.Absorb(r, progress)
+	done()
+	return state, n, err
+}
+
+// plainHash returns the regular hash of path ("-" for stdin) or, if path is
+// empty, of the literal input string, and the number of bytes hashed. Files
+// go through HashReader like qhash.New digests.
+func plainHash(hasher *qhash.HardenedLorenzHasher, path, literal string) ([]byte, int64, error) {
+	if path == "" {
+		h, err := hasher.Hash([]byte(literal))
+		return h, int64(len(literal)), err
+	}
+	r, err := openInput(path, "")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer r.Close()
+
+	var n int64
+	progress, done := absorbProgress()
+	h, err := hasher.HashReader(r, func(total int64) {
+		n = total
+		progress(total)
+	})
+	done()
+	return h, n, err
+}
+
+// absorbProgress returns a progress callback that reports large inputs on
+// stderr, and a function that ends the report line.
+func absorbProgress() (qhash.ProgressFunc, func()) {
+	var reported int64
+	progress := func(n int64) {
+		if n-reported >= progressThreshold {
+			fmt.Fprintf(os.Stderr, "\rAbsorbed %.1f MiB", float64(n)/(1<<20))
+			reported = n
+		}
+	}
+	done := func() {
+		if reported > 0 {
+			fmt.Fprintln(os.Stderr)
+		}
+	}
+	return progress, done
+}
+
+func verifyHardenedHash(data []byte, hjson string, hasher *qhash.HardenedLorenzHasher, progressive bool) error {
+	stored, err := decodeHardenedHash(hjson)
+	if err != nil {
+		return err
+	}
+
+	ok, err := hasher.VerifyProgressive(context.Background(), data, stored, !progressive)
 	if err != nil {
 		return fmt.Errorf("verification error: %w", err)
 	}
@@ -153,18 +300,37 @@ func verifyHardenedHash(data []byte, hjson string, hasher *qhash.HardenedLorenzH
 	return nil
 }
 
-func verifyLegacyHash(data []byte, hash64 string, hasher *qhash.HardenedLorenzHasher) error {
-	expected, err := base64.StdEncoding.DecodeString(hash64)
+// decodeHardenedHash accepts a $qhash$ modular crypt string, raw JSON, or
+// base64 encoded JSON.
+func decodeHardenedHash(encoded string) (*qhash.HardenedSaltedHash, error) {
+	if strings.HasPrefix(encoded, qhash.PHCPrefix) {
+		stored, err := qhash.ParsePHC(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("qhash string decode error: %w", err)
+		}
+		return stored, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(encoded)
 	if err != nil {
-		return fmt.Errorf("base64 decode error: %w", err)
+		// Try as raw JSON if base64 decode fails
+		raw = []byte(encoded)
 	}
 
-	got, err := hasher.Hash(data)
+	var stored qhash.HardenedSaltedHash
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		return nil, fmt.Errorf("JSON decode error: %w", err)
+	}
+	return &stored, nil
+}
+
+func verifyLegacyHash(got []byte, hash64 string) error {
+	expected, err := base64.StdEncoding.DecodeString(hash64)
 	if err != nil {
-		return fmt.Errorf("hashing error: %w", err)
+		return fmt.Errorf("base64 decode error: %w", err)
 	}
 
-	ok := bytes.Equal(got, expected)
+	ok := subtle.ConstantTimeCompare(got, expected) == 1
 	fmt.Println("Legacy OK:", ok)
 	return nil
 }
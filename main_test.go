@@ -0,0 +1,60 @@
+// main_test.go
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"chaos/v2/qhash"
+)
+
+func TestPlainHashFileMatchesLibrary(t *testing.T) {
+	content := bytes.Repeat([]byte("chaos file hash "), 1000)
+	path := filepath.Join(t.TempDir(), "input.bin")
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"", "secret key"} {
+		// The CLI's defaults, including the memory-hard phase that plain
+		// file hashes must not use
+		opts := []qhash.Option{
+			qhash.WithMemoryHardness(qhash.DefaultMemoryHardness),
+			qhash.WithoutMinComputeTime(),
+		}
+		if key != "" {
+			opts = append(opts, qhash.WithKey([]byte(key)))
+		}
+		hasher, err := qhash.NewHardenedLorenzHasher(256, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, n, err := plainHash(hasher, path, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(len(content)) {
+			t.Errorf("key %q: hashed %d bytes, want %d", key, n, len(content))
+		}
+
+		d, err := qhash.New(256, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.Write(content)
+		if want := d.Sum(nil); !bytes.Equal(got, want) {
+			t.Errorf("key %q: CLI file hash %x, qhash.New %x", key, got, want)
+		}
+
+		want, err := hasher.HashReader(bytes.NewReader(content), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("key %q: CLI file hash %x, HashReader %x", key, got, want)
+		}
+	}
+}
@@ -7,27 +7,81 @@ package qhash
 import (
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
+	"math"
+	"math/big"
 )
 
+// AdaptiveParameters are input-dependent perturbations applied to every
+// stage from algorithm version 2.1 onward.
+type AdaptiveParameters struct {
+	IterationMultiplier    float64 `json:"iteration_multiplier"`
+	DtScale                float64 `json:"dt_scale"`
+	MemoryMultiplier       float64 `json:"memory_multiplier"`
+	SigmaPerturbation      float64 `json:"sigma_perturbation"`
+	RhoPerturbation        float64 `json:"rho_perturbation"`
+	BetaPerturbation       float64 `json:"beta_perturbation"`
+	QuantumResistanceLevel int     `json:"quantum_resistance_level"`
+}
+
 // deriveAdaptiveParameters creates deterministic parameters from input.
-func deriveAdaptiveParameters(data, salt []byte) map[string]interface{} {
+func deriveAdaptiveParameters(data, salt []byte) *AdaptiveParameters {
 	combined := make([]byte, 0, len(data)+len(salt))
 	combined = append(combined, data...)
 	combined = append(combined, salt...)
 
 	h := sha256.Sum256(combined)
-	params := make(map[string]interface{})
-
-	// Derive parameters within safe ranges
-	params["iteration_multiplier"] = 0.8 + (float64(h[0])/255.0)*0.4 // [0.8, 1.2]
-	params["dt_scale"] = 0.9 + (float64(h[1])/255.0)*0.2             // [0.9, 1.1]
-	params["memory_multiplier"] = 1.0 + (float64(h[2])/255.0)*1.0    // [1.0, 2.0]
-	params["sigma_perturbation"] = (float64(h[3])/255.0 - 0.5) * 1.0 // [-0.5, 0.5]
-	params["rho_perturbation"] = (float64(h[4])/255.0 - 0.5) * 2.0   // [-1.0, 1.0]
-	params["beta_perturbation"] = (float64(h[5])/255.0 - 0.5) * 0.5  // [-0.25, 0.25]
-	params["quantum_resistance_level"] = int(h[6])%4 + 1             // [1, 4]
-
-	return params
+
+	// Derive parameters within safe ranges. The float64 conversions round
+	// each product before the sum, which forbids fused multiply-add and keeps
+	// the values identical on every platform.
+	return &AdaptiveParameters{
+		IterationMultiplier:    0.8 + float64((float64(h[0])/255.0)*0.4), // [0.8, 1.2]
+		DtScale:                0.9 + float64((float64(h[1])/255.0)*0.2), // [0.9, 1.1]
+		MemoryMultiplier:       1.0 + float64((float64(h[2])/255.0)*1.0), // [1.0, 2.0]
+		SigmaPerturbation:      (float64(h[3])/255.0 - 0.5) * 1.0,        // [-0.5, 0.5]
+		RhoPerturbation:        (float64(h[4])/255.0 - 0.5) * 2.0,        // [-1.0, 1.0]
+		BetaPerturbation:       (float64(h[5])/255.0 - 0.5) * 0.5,        // [-0.25, 0.25]
+		QuantumResistanceLevel: int(h[6])%4 + 1,                          // [1, 4]
+	}
+}
+
+// apply returns a stage's parameters, step size and iteration count
+// perturbed by p. Only Lorenz parameters are perturbed; other systems keep
+// theirs. Iterations are clamped to MaxIterations.
+func (p *AdaptiveParameters) apply(
+	sys ChaoticSystem, params []*big.Float, dt *big.Float, iterations int,
+) ([]*big.Float, *big.Float, int) {
+	add := func(v *big.Float, d float64) *big.Float {
+		return new(big.Float).SetPrec(128).Add(v, big.NewFloat(d))
+	}
+
+	if sys.Name() == SystemLorenz {
+		params = []*big.Float{
+			add(params[0], p.SigmaPerturbation),
+			add(params[1], p.RhoPerturbation),
+			add(params[2], p.BetaPerturbation),
+		}
+	}
+	dt = new(big.Float).SetPrec(128).Mul(dt, big.NewFloat(p.DtScale))
+
+	// Config stages may start at MinIterations, which the multiplier must
+	// not push below the trajectory's safe range.
+	iters := int(math.Round(float64(iterations) * p.IterationMultiplier))
+	iters = min(max(iters, MinIterations), MaxIterations)
+	return params, dt, iters
+}
+
+// bytes returns a fixed binary encoding of p for constant-time comparison.
+func (p *AdaptiveParameters) bytes() []byte {
+	out := make([]byte, 0, 7*8)
+	for _, f := range []float64{
+		p.IterationMultiplier, p.DtScale, p.MemoryMultiplier,
+		p.SigmaPerturbation, p.RhoPerturbation, p.BetaPerturbation,
+	} {
+		out = binary.BigEndian.AppendUint64(out, math.Float64bits(f))
+	}
+	return binary.BigEndian.AppendUint64(out, uint64(p.QuantumResistanceLevel))
 }
 
 // deriveSaltLR produces a salt of the given size via chained SHA256.
@@ -0,0 +1,135 @@
+// =======================
+// qhash/aead.go
+// =======================
+
+package qhash
+
+import (
+	"crypto/cipher"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	AEADKeySize   = 32
+	AEADNonceSize = 16
+	AEADOverhead  = 32 // HMAC-QHASH-256 tag
+)
+
+const aeadDomain = "QHASH-AEAD-v2"
+
+var errOpen = errors.New("qhash: message authentication failed")
+
+// lorenzAEAD encrypts by XOR with a Lorenz keystream seeded from the key and
+// nonce, then authenticates with HMAC-QHASH-256 (encrypt-then-MAC).
+type lorenzAEAD struct {
+	encKey []byte
+	macKey []byte
+}
+
+// NewAEAD returns an EXPERIMENTAL cipher.AEAD keyed by a AEADKeySize-byte
+// key. Encryption XORs the plaintext with a keystream of SHA-512 whitened
+// blocks squeezed from a Lorenz trajectory seeded by seedState(key, nonce),
+// so ciphertext does not expose the trajectory state; the tag is
+// HMAC-QHASH-256 over the nonce, additional data and ciphertext. A nonce
+// must never be reused with the same key. Besides the keystream, every Seal
+// or Open costs one NewMAC tag: two QHASH-256 computations without the
+// memory-hard phase or minimum compute time.
+//
+// The construction has not been analysed; do not use it to protect real
+// data.
+func NewAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != AEADKeySize {
+		return nil, fmt.Errorf("invalid key size: %d, expected %d", len(key), AEADKeySize)
+	}
+	sub := sha512.Sum512(append([]byte(aeadDomain), key...))
+	return &lorenzAEAD{encKey: sub[:32], macKey: sub[32:]}, nil
+}
+
+func (a *lorenzAEAD) NonceSize() int { return AEADNonceSize }
+
+func (a *lorenzAEAD) Overhead() int { return AEADOverhead }
+
+func (a *lorenzAEAD) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != AEADNonceSize {
+		panic("qhash: incorrect nonce length given to AEAD")
+	}
+
+	ret, out := sliceForAppend(dst, len(plaintext)+AEADOverhead)
+	ks, err := newKeystream(a.encKey, nonce)
+	if err == nil {
+		err = ks.xor(out, plaintext)
+	}
+	if err != nil {
+		panic(fmt.Sprintf("qhash: keystream failed: %v", err))
+	}
+
+	tag, err := a.tag(nonce, additionalData, out[:len(plaintext)])
+	if err != nil {
+		panic(fmt.Sprintf("qhash: tag failed: %v", err))
+	}
+	copy(out[len(plaintext):], tag)
+	return ret
+}
+
+func (a *lorenzAEAD) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != AEADNonceSize {
+		panic("qhash: incorrect nonce length given to AEAD")
+	}
+	if len(ciphertext) < AEADOverhead {
+		return nil, errOpen
+	}
+
+	n := len(ciphertext) - AEADOverhead
+	tag, err := a.tag(nonce, additionalData, ciphertext[:n])
+	if err != nil {
+		return nil, err
+	}
+	if !Equal(tag, ciphertext[n:]) {
+		return nil, errOpen
+	}
+
+	ret, out := sliceForAppend(dst, n)
+	ks, err := newKeystream(a.encKey, nonce)
+	if err != nil {
+		return nil, err
+	}
+	if err := ks.xor(out, ciphertext[:n]); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// tag authenticates nonce, additional data and ciphertext. The lengths are
+// appended so that the split between additional data and ciphertext is
+// unambiguous.
+func (a *lorenzAEAD) tag(nonce, additionalData, ciphertext []byte) ([]byte, error) {
+	mac, err := NewMAC(a.macKey, AEADOverhead*8)
+	if err != nil {
+		return nil, err
+	}
+	mac.Write([]byte(aeadDomain))
+	mac.Write(nonce)
+	mac.Write(additionalData)
+	mac.Write(ciphertext)
+	var lens [16]byte
+	binary.BigEndian.PutUint64(lens[:8], uint64(len(additionalData)))
+	binary.BigEndian.PutUint64(lens[8:], uint64(len(ciphertext)))
+	mac.Write(lens[:])
+	return mac.Sum(nil), nil
+}
+
+// sliceForAppend extends in by n bytes, reusing its capacity when possible,
+// and returns the whole slice and the n new bytes.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
@@ -0,0 +1,87 @@
+// =======================
+// qhash/aead_test.go
+// =======================
+
+package qhash
+
+import (
+	"bytes"
+	"testing"
+)
+
+func newTestAEAD(t *testing.T, keyByte byte) *lorenzAEAD {
+	t.Helper()
+	a, err := NewAEAD(bytes.Repeat([]byte{keyByte}, AEADKeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a.(*lorenzAEAD)
+}
+
+func TestAEADRoundTrip(t *testing.T) {
+	a := newTestAEAD(t, 1)
+	nonce := bytes.Repeat([]byte{2}, AEADNonceSize)
+	plain := []byte("attack at dawn, bring the lorenz attractor")
+	ad := []byte("header")
+
+	sealed := a.Seal([]byte("prefix"), nonce, plain, ad)
+	if !bytes.HasPrefix(sealed, []byte("prefix")) {
+		t.Fatal("Seal did not append to dst")
+	}
+	ct := sealed[len("prefix"):]
+	if len(ct) != len(plain)+AEADOverhead {
+		t.Fatalf("ciphertext length %d, want %d", len(ct), len(plain)+AEADOverhead)
+	}
+	if bytes.Contains(ct, plain[:8]) {
+		t.Error("ciphertext contains the plaintext")
+	}
+
+	got, err := a.Open(nil, nonce, ct, ad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Errorf("opened %q, want %q", got, plain)
+	}
+
+	empty := a.Seal(nil, nonce, nil, nil)
+	if got, err := a.Open(nil, nonce, empty, nil); err != nil || len(got) != 0 {
+		t.Errorf("empty message: %q, %v", got, err)
+	}
+}
+
+func TestAEADRejectsTampering(t *testing.T) {
+	a := newTestAEAD(t, 1)
+	nonce := bytes.Repeat([]byte{2}, AEADNonceSize)
+	plain := []byte("attack at dawn")
+	ad := []byte("header")
+	ct := a.Seal(nil, nonce, plain, ad)
+
+	flip := func(b []byte, i int) []byte {
+		c := append([]byte(nil), b...)
+		c[i] ^= 1
+		return c
+	}
+	otherNonce := flip(nonce, 0)
+	tests := []struct {
+		name          string
+		a             *lorenzAEAD
+		nonce, ct, ad []byte
+	}{
+		{"ciphertext byte", a, nonce, flip(ct, 3), ad},
+		{"tag byte", a, nonce, flip(ct, len(ct)-1), ad},
+		{"additional data", a, nonce, ct, flip(ad, 0)},
+		{"missing additional data", a, nonce, ct, nil},
+		{"nonce", a, otherNonce, ct, ad},
+		{"truncated", a, nonce, ct[:len(ct)-1], ad},
+		{"truncated to the tag", a, nonce, ct[len(plain):], ad},
+		{"shorter than a tag", a, nonce, ct[:AEADOverhead-1], ad},
+		{"extended", a, nonce, append(append([]byte(nil), ct...), 0), ad},
+		{"other key", newTestAEAD(t, 3), nonce, ct, ad},
+	}
+	for _, tt := range tests {
+		if got, err := tt.a.Open(nil, tt.nonce, tt.ct, tt.ad); err == nil {
+			t.Errorf("%s: opened to %q", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// =======================
+// qhash/algorithm.go
+// =======================
+
+package qhash
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// algorithm is one released version of the hardened computation. Versions
+// stay registered after they are superseded so stored hashes keep
+// verifying.
+type algorithm struct {
+	version  string
+	adaptive bool    // adaptive parameters perturb the stages
+	mix      mixFunc // hyperchaos round of quantumFinalize
+}
+
+// mixFunc is the hyperchaos round of quantumFinalize.
+type mixFunc func(ctx context.Context, engine Engine, data, salt []byte) ([]byte, error)
+
+// engineless adapts a hyperchaos round that ignores the selected engine.
+func engineless(mix func(data, salt []byte) ([]byte, error)) mixFunc {
+	return func(_ context.Context, _ Engine, data, salt []byte) ([]byte, error) {
+		return mix(data, salt)
+	}
+}
+
+var algorithms = map[string]algorithm{
+	AlgorithmVersion:  {version: AlgorithmVersion, adaptive: true, mix: hyperchaosMix},
+	HyperchaosVersion: {version: HyperchaosVersion, adaptive: true, mix: engineless(bigHyperchaosMix)},
+	AdaptiveVersion:   {version: AdaptiveVersion, adaptive: true, mix: engineless(legacyHyperchaosMix)},
+	LegacyVersion:     {version: LegacyVersion, adaptive: false, mix: engineless(legacyHyperchaosMix)},
+}
+
+// algorithmName returns the algorithm identifier recorded for size-bit
+// hashes.
+func algorithmName(size int) string {
+	return fmt.Sprintf("QHASH-%d", size)
+}
+
+// lookupAlgorithm returns the algorithm a size-bit hash recorded with the
+// identifier name and version was produced by. An empty name predates its
+// recording and an empty version is LegacyVersion.
+func lookupAlgorithm(name string, size int, version string) (algorithm, error) {
+	if name != "" && name != algorithmName(size) {
+		return algorithm{}, fmt.Errorf("unsupported algorithm: %s", name)
+	}
+	if version == "" {
+		version = LegacyVersion
+	}
+	alg, ok := algorithms[version]
+	if !ok {
+		return algorithm{}, fmt.Errorf("unsupported algorithm version: %s", version)
+	}
+	return alg, nil
+}
+
+// Upgrade verifies data against stored and, if it matches but stored was
+// produced by an older algorithm version or with other settings than h,
+// returns a fresh hash of data made by h for the caller to store. The
+// returned hash is nil when stored does not match or is already current.
+func (h *HardenedLorenzHasher) Upgrade(
+	data []byte, stored *HardenedSaltedHash,
+) (bool, *HardenedSaltedHash, error) {
+	ok, err := h.VerifyHardenedHash(data, stored)
+	if err != nil || !ok {
+		return ok, nil, err
+	}
+	if !h.outdated(stored) {
+		return true, nil, nil
+	}
+
+	upgraded, err := h.HashWithHardening(data)
+	if err != nil {
+		return true, nil, fmt.Errorf("rehash failed: %w", err)
+	}
+	return true, upgraded, nil
+}
+
+// outdated reports whether h would produce stored differently today.
+func (h *HardenedLorenzHasher) outdated(stored *HardenedSaltedHash) bool {
+	stages := h.stages[h.hashSize]
+	return stored.Version != AlgorithmVersion ||
+		stored.HashSize != int(h.hashSize) ||
+		stored.Config != h.config ||
+		storedCost(stored) != h.cost() ||
+		stored.Engine != h.engine ||
+		!slices.Equal(stored.Integrators, stageIntegrators(stages)) ||
+		!slices.Equal(stored.Systems, stageSystems(stages))
+}
@@ -0,0 +1,97 @@
+// =======================
+// qhash/config_test.go
+// =======================
+
+package qhash
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStageConfigExample(t *testing.T) {
+	cfg, err := LoadStageConfig("../docs/stages.example.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.HashSize != 512 || len(cfg.Stages) != 3 {
+		t.Fatalf("example has size %d and %d stages, want 512 and 3", cfg.HashSize, len(cfg.Stages))
+	}
+	for i, sys := range []string{SystemLorenz, SystemRossler, SystemHyperLorenz} {
+		if cfg.Stages[i].System != sys {
+			t.Errorf("stage %d system %q, want %q", i, cfg.Stages[i].System, sys)
+		}
+	}
+
+	h, err := NewHasherFromConfig(cfg, WithMemoryHardness(0), WithoutMinComputeTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := h.HashWithHardening([]byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Config == "" || stored.Config != h.config {
+		t.Errorf("hash records config %q, want %q", stored.Config, h.config)
+	}
+	if ok, err := h.VerifyHardenedHash([]byte("data"), stored); err != nil || !ok {
+		t.Errorf("example config hash does not verify: %v, %v", ok, err)
+	}
+}
+
+func TestParseStageConfigUnknownFields(t *testing.T) {
+	for _, js := range []string{
+		`{"hash_size": 256, "stages": [{"iterations": 2000, "dt": 0.01, "integrater": "rk4"}]}`,
+		`{"hash_size": 256, "stage": [{"iterations": 2000, "dt": 0.01}]}`,
+	} {
+		if _, err := ParseStageConfig(strings.NewReader(js)); err == nil {
+			t.Errorf("%s accepted", js)
+		}
+	}
+
+	cfg, err := ParseStageConfig(strings.NewReader(
+		`{"hash_size": 256, "stages": [{"iterations": 2000, "dt": 0.01, "integrator": "rk4"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Stages) != 1 || cfg.Stages[0].Integrator != "rk4" {
+		t.Errorf("parsed %+v", cfg)
+	}
+}
+
+func TestVerifyRejectsOtherConfig(t *testing.T) {
+	newConfigHasher := func(iterations int) *HardenedLorenzHasher {
+		t.Helper()
+		h, err := NewHasherFromConfig(StageConfig{
+			HashSize: 256,
+			Stages:   []StageSpec{{Iterations: iterations, Dt: 0.01}},
+		}, WithMemoryHardness(0), WithoutMinComputeTime())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	a, b := newConfigHasher(1000), newConfigHasher(1500)
+	if a.config == b.config {
+		t.Fatal("different stage tables share a fingerprint")
+	}
+
+	stored, err := a.HashWithHardening([]byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.verifySpec([]byte("data"), stored); err == nil {
+		t.Error("hash verified against another stage config")
+	}
+
+	builtin, err := NewHardenedLorenzHasher(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := builtin.VerifyHardenedHash([]byte("data"), stored); err == nil {
+		t.Error("config hash verified against the built-in stages")
+	}
+	if _, err := a.verifySpec([]byte("data"), stored); err != nil {
+		t.Errorf("own config rejected: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+// =======================
+// qhash/digest.go
+// =======================
+
+package qhash
+
+import (
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// AbsorbChunkSize is the read size used when absorbing from an io.Reader.
+const AbsorbChunkSize = 64 * 1024
+
+// ProgressFunc receives the running number of bytes absorbed.
+type ProgressFunc func(absorbed int64)
+
+// digest is a streaming hash.Hash over the deterministic Lorenz pipeline.
+// Input is absorbed into a SHA-512 state; Sum runs the Lorenz stages over the
+// compressed state, so a digest differs from Hash over the same bytes. The
+// hasher runs without the memory-hard phase and the minimum compute time,
+// which only serve password hashing.
+type digest struct {
+	hasher *HardenedLorenzHasher
+	inner  hash.Hash
+	length uint64
+}
+
+// New returns a streaming hash.Hash producing size-bit QHASH digests.
+// WithMemoryHardness does not affect digests.
+func New(size int, opts ...Option) (hash.Hash, error) {
+	hasher, err := NewHardenedLorenzHasher(size, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return hasher.NewDigest(), nil
+}
+
+// Absorb streams r through the chunk-absorbing front end and returns the
+// compressed state that the Lorenz stages consume, along with the number of
+// bytes read. HashReader and digests hash this state.
+func Absorb(r io.Reader, progress ProgressFunc) ([]byte, int64, error) {
+	d := &digest{inner: sha512.New()}
+	buf := make([]byte, AbsorbChunkSize)
+
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			d.Write(buf[:n])
+			if progress != nil {
+				progress(int64(d.length))
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, int64(d.length), fmt.Errorf("read failed: %w", err)
+		}
+	}
+
+	return d.state(), int64(d.length), nil
+}
+
+// HashReader returns the digest of everything read from r without buffering
+// the input in memory.
+func (h *HardenedLorenzHasher) HashReader(r io.Reader, progress ProgressFunc) ([]byte, error) {
+	state, _, err := Absorb(r, progress)
+	if err != nil {
+		return nil, err
+	}
+	return h.unhardened().Hash(state)
+}
+
+// NewDigest returns a streaming hash.Hash bound to h's size, key, stages
+// and engine.
+func (h *HardenedLorenzHasher) NewDigest() hash.Hash {
+	return &digest{hasher: h.unhardened(), inner: sha512.New()}
+}
+
+func (d *digest) Write(p []byte) (int, error) {
+	n, err := d.inner.Write(p)
+	d.length += uint64(n)
+	return n, err
+}
+
+// Sum appends the digest of the data written so far to b. It does not
+// change the underlying state. Without the memory-hard phase the only error
+// Hash can return is a stage that still overflows at maxSubsteps, which no
+// input is known to cause, so Sum panics rather than return a short digest.
+func (d *digest) Sum(b []byte) []byte {
+	sum, err := d.hasher.Hash(d.state())
+	if err != nil {
+		panic(fmt.Sprintf("qhash: digest computation failed: %v", err))
+	}
+	return append(b, sum...)
+}
+
+func (d *digest) Reset() {
+	d.inner.Reset()
+	d.length = 0
+}
+
+func (d *digest) Size() int { return int(d.hasher.hashSize) / 8 }
+
+func (d *digest) BlockSize() int { return d.inner.BlockSize() }
+
+// state compresses everything absorbed so far into a fixed-size block that
+// seeds the Lorenz stages. The length suffix keeps it non-empty.
+func (d *digest) state() []byte {
+	st := d.inner.Sum(nil)
+	return binary.BigEndian.AppendUint64(st, d.length)
+}
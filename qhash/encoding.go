@@ -0,0 +1,258 @@
+// =======================
+// qhash/encoding.go
+// =======================
+
+package qhash
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PHCPrefix identifies QHASH modular crypt strings.
+const PHCPrefix = "$qhash$"
+
+// phcB64 is the unpadded standard alphabet used by the PHC string format.
+var phcB64 = base64.RawStdEncoding
+
+// EncodePHC renders s as a compact modular crypt string:
+//
+//	$qhash$v=2.2$s=512,e=491234,t=1,m=512,p=1,l=4,i=rk4-euler-euler-euler$<master salt>$<hash>
+//
+// Only the master salt and the parameters needed to re-derive the rest of the
+// salt hierarchy are stored. Checkpoints are dropped.
+func (s *HardenedSaltedHash) EncodePHC() (string, error) {
+	if s == nil || s.Salt == nil || len(s.Salt.MasterSalt) == 0 || len(s.Hash) == 0 {
+		return "", fmt.Errorf("invalid hardened hash")
+	}
+
+	size := HashSize(s.HashSize)
+	numStages := len(s.Salt.StageSalts)
+	if len(defaultStages(size)) == 0 {
+		return "", fmt.Errorf("unsupported hash size: %d", s.HashSize)
+	}
+
+	derived, err := buildSaltHierarchy(s.Salt.MasterSalt, s.Salt.EpochHour,
+		len(s.Salt.StageSalts), s.HashSize)
+	if err != nil {
+		return "", fmt.Errorf("salt re-derivation failed: %w", err)
+	}
+	if !sameSaltHierarchy(derived, s.Salt) {
+		return "", fmt.Errorf("salt hierarchy is not derivable from its master salt")
+	}
+
+	params := []string{"s=" + strconv.Itoa(s.HashSize)}
+	if numStages != len(defaultStages(size)) {
+		params = append(params, "n="+strconv.Itoa(numStages))
+	}
+	if s.Salt.EpochHour != 0 {
+		params = append(params, "e="+strconv.FormatInt(s.Salt.EpochHour, 10))
+	}
+	if s.TimeCost != 0 {
+		params = append(params, "t="+strconv.Itoa(s.TimeCost))
+	}
+	if s.MemoryCost != 0 {
+		params = append(params, "m="+strconv.Itoa(s.MemoryCost))
+	}
+	if s.Parallelism != 0 {
+		params = append(params, "p="+strconv.Itoa(s.Parallelism))
+	}
+	if s.Lanes != 0 {
+		params = append(params, "l="+strconv.Itoa(s.Lanes))
+	}
+	if len(s.Integrators) != 0 {
+		params = append(params, "i="+strings.Join(s.Integrators, "-"))
+	}
+	if s.Engine != "" {
+		params = append(params, "g="+s.Engine)
+	}
+	if len(s.Systems) != 0 {
+		params = append(params, "c="+strings.Join(s.Systems, "-"))
+	}
+	if s.Config != "" {
+		params = append(params, "f="+s.Config)
+	}
+
+	return fmt.Sprintf("%sv=%s$%s$%s$%s",
+		PHCPrefix,
+		strings.TrimSuffix(s.Version, ".0"),
+		strings.Join(params, ","),
+		phcB64.EncodeToString(s.Salt.MasterSalt),
+		phcB64.EncodeToString(s.Hash),
+	), nil
+}
+
+// ParsePHC decodes a string produced by EncodePHC, re-deriving the full salt
+// hierarchy from the stored master salt.
+func ParsePHC(encoded string) (*HardenedSaltedHash, error) {
+	if !strings.HasPrefix(encoded, PHCPrefix) {
+		return nil, fmt.Errorf("not a qhash string")
+	}
+
+	fields := strings.Split(strings.TrimPrefix(encoded, PHCPrefix), "$")
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("malformed qhash string: expected 4 fields, got %d", len(fields))
+	}
+
+	version, ok := strings.CutPrefix(fields[0], "v=")
+	if !ok || version == "" {
+		return nil, fmt.Errorf("missing version field")
+	}
+	if !strings.Contains(version, ".") {
+		version += ".0"
+	}
+
+	params, err := parsePHCParams(fields[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := params["s"]; !ok {
+		return nil, fmt.Errorf("missing size parameter")
+	}
+	ints := make(map[string]int64)
+	for _, k := range []string{"s", "n", "e", "t", "m", "p", "l"} {
+		if v, ok := params[k]; ok {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parameter %q: %w", k, err)
+			}
+			ints[k] = n
+		}
+	}
+	size := ints["s"]
+	numStages := int64(len(defaultStages(HashSize(size))))
+	if numStages == 0 {
+		return nil, fmt.Errorf("unsupported hash size: %d", size)
+	}
+	if n, ok := ints["n"]; ok {
+		if n < 1 || n > MaxStages {
+			return nil, fmt.Errorf("stage count out of range: %d", n)
+		}
+		numStages = n
+	}
+	for _, b := range phcCostBounds {
+		if v, ok := ints[b.key]; ok && (v < b.min || v > b.max) {
+			return nil, fmt.Errorf("%s out of range: %d", b.name, v)
+		}
+	}
+
+	master, err := phcB64.DecodeString(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("salt decode error: %w", err)
+	}
+	if len(master) == 0 {
+		return nil, fmt.Errorf("empty salt")
+	}
+
+	sum, err := phcB64.DecodeString(fields[3])
+	if err != nil {
+		return nil, fmt.Errorf("hash decode error: %w", err)
+	}
+	if len(sum) != int(size)/8 {
+		return nil, fmt.Errorf("hash length %d does not match size %d", len(sum), size)
+	}
+
+	var integrators []string
+	if v, ok := params["i"]; ok {
+		integrators = strings.Split(v, "-")
+		if int64(len(integrators)) != numStages {
+			return nil, fmt.Errorf("integrators cover %d stages, expected %d",
+				len(integrators), numStages)
+		}
+	}
+
+	var systems []string
+	if v, ok := params["c"]; ok {
+		systems = strings.Split(v, "-")
+		if int64(len(systems)) != numStages {
+			return nil, fmt.Errorf("systems cover %d stages, expected %d",
+				len(systems), numStages)
+		}
+	}
+
+	salt, err := buildSaltHierarchy(master, ints["e"], int(numStages), int(size))
+	if err != nil {
+		return nil, fmt.Errorf("salt re-derivation failed: %w", err)
+	}
+
+	return &HardenedSaltedHash{
+		Hash:        sum,
+		Salt:        salt,
+		Algorithm:   algorithmName(int(size)),
+		Version:     version,
+		HashSize:    int(size),
+		TimeCost:    int(ints["t"]),
+		MemoryCost:  int(ints["m"]),
+		Parallelism: int(ints["p"]),
+		Lanes:       int(ints["l"]),
+		Integrators: integrators,
+		Engine:      params["g"],
+		Systems:     systems,
+		Config:      params["f"],
+	}, nil
+}
+
+// phcParams lists the parameter keys ParsePHC understands.
+var phcParams = map[string]bool{
+	"s": true, // hash size in bits
+	"n": true, // stage count, when it differs from the built-in table
+	"e": true, // epoch hour bound into the timestamp salt
+	"t": true, // time cost
+	"m": true, // memory cost in KiB
+	"p": true, // parallelism
+	"l": true, // stage lanes
+	"i": true, // per-stage integrators, dash separated
+	"g": true, // trajectory engine
+	"c": true, // per-stage chaotic systems, dash separated
+	"f": true, // stage config fingerprint
+}
+
+// phcCostBounds limits the work factors a string may request, so a crafted
+// one is rejected before verification allocates anything.
+var phcCostBounds = []struct {
+	key, name string
+	min, max  int64
+}{
+	{"t", "time cost", 1, MaxTimeCost},
+	{"m", "memory cost", 0, MaxMemoryHardness},
+	{"p", "parallelism", 1, MaxParallelism},
+	{"l", "lanes", 1, MaxLanes},
+}
+
+// parsePHCParams parses a comma separated list of key=value pairs.
+func parsePHCParams(field string) (map[string]string, error) {
+	params := make(map[string]string)
+	for _, kv := range strings.Split(field, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("malformed parameter %q", kv)
+		}
+		if !phcParams[k] {
+			return nil, fmt.Errorf("unknown parameter %q", k)
+		}
+		if _, dup := params[k]; dup {
+			return nil, fmt.Errorf("duplicate parameter %q", k)
+		}
+		params[k] = v
+	}
+	return params, nil
+}
+
+// sameSaltHierarchy reports whether two hierarchies hold identical salts.
+func sameSaltHierarchy(a, b *HierarchicalSalt) bool {
+	if len(a.StageSalts) != len(b.StageSalts) {
+		return false
+	}
+	for i := range a.StageSalts {
+		if !bytes.Equal(a.StageSalts[i], b.StageSalts[i]) {
+			return false
+		}
+	}
+	return bytes.Equal(a.MasterSalt, b.MasterSalt) &&
+		bytes.Equal(a.TimestampSalt, b.TimestampSalt) &&
+		bytes.Equal(a.MetaSalt, b.MetaSalt)
+}
@@ -0,0 +1,129 @@
+// =======================
+// qhash/encoding_test.go
+// =======================
+
+package qhash
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// phcFixture returns a 256-bit hardened hash that sets every optional PHC
+// field.
+func phcFixture(t *testing.T) *HardenedSaltedHash {
+	t.Helper()
+	salt, err := buildSaltHierarchy(bytes.Repeat([]byte{7}, 32), 497821, 3, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &HardenedSaltedHash{
+		Hash:        bytes.Repeat([]byte{0x5c}, 32),
+		Salt:        salt,
+		Algorithm:   algorithmName(256),
+		Version:     AlgorithmVersion,
+		HashSize:    256,
+		TimeCost:    2,
+		MemoryCost:  1024,
+		Parallelism: 2,
+		Lanes:       3,
+		Integrators: []string{"rk4", "euler", "dopri5"},
+		Engine:      EngineFixed,
+		Systems:     []string{"lorenz", "rossler", "lorenz"},
+		Config:      "0123456789abcdef0123456789abcdef",
+	}
+}
+
+func TestPHCRoundTrip(t *testing.T) {
+	want := phcFixture(t)
+	encoded, err := want.EncodePHC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"n=3", "e=497821", "t=2", "m=1024", "p=2", "l=3",
+		"i=rk4-euler-dopri5", "g=fixed96", "c=lorenz-rossler-lorenz",
+		"f=0123456789abcdef0123456789abcdef"} {
+		if !strings.Contains(encoded, field) {
+			t.Errorf("%s lacks %s", encoded, field)
+		}
+	}
+
+	got, err := ParsePHC(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip of %s:\ngot  %+v\nwant %+v", encoded, got, want)
+	}
+}
+
+func TestPHCRoundTripDefaults(t *testing.T) {
+	want := phcFixture(t)
+	want.Salt, _ = buildSaltHierarchy(want.Salt.MasterSalt, 0, 2, 256)
+	want.TimeCost, want.MemoryCost, want.Parallelism, want.Lanes = 0, 0, 0, 0
+	want.Integrators, want.Engine, want.Systems, want.Config = nil, "", nil, ""
+
+	encoded, err := want.EncodePHC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields := strings.Split(encoded, "$"); fields[3] != "s=256" {
+		t.Errorf("%s encodes optional fields", encoded)
+	}
+	got, err := ParsePHC(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip of %s:\ngot  %+v\nwant %+v", encoded, got, want)
+	}
+}
+
+func TestParsePHCRejects(t *testing.T) {
+	encoded, err := phcFixture(t).EncodePHC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := strings.Split(strings.TrimPrefix(encoded, PHCPrefix), "$")
+	params := fields[1]
+	build := func(version, params, salt, sum string) string {
+		return PHCPrefix + strings.Join([]string{version, params, salt, sum}, "$")
+	}
+	withParams := func(p string) string { return build(fields[0], p, fields[2], fields[3]) }
+
+	tests := []struct {
+		name, encoded string
+	}{
+		{"prefix", strings.TrimPrefix(encoded, PHCPrefix)},
+		{"too few segments", PHCPrefix + strings.Join(fields[:3], "$")},
+		{"too many segments", encoded + "$x"},
+		{"missing version", build("", params, fields[2], fields[3])},
+		{"bad salt base64", build(fields[0], params, "!!!", fields[3])},
+		{"bad hash base64", build(fields[0], params, fields[2], "!!!")},
+		{"padded hash", build(fields[0], params, fields[2], fields[3]+"=")},
+		{"short hash", build(fields[0], params, fields[2], fields[3][:20])},
+		{"empty salt", build(fields[0], params, "", fields[3])},
+		{"duplicate key", withParams(params + ",t=2")},
+		{"unknown key", withParams(params + ",x=1")},
+		{"malformed parameter", withParams(params + ",t")},
+		{"missing size", withParams(strings.TrimPrefix(params, "s=256,"))},
+		{"unsupported size", withParams(strings.Replace(params, "s=256", "s=128", 1))},
+		{"non-numeric cost", withParams(strings.Replace(params, "t=2", "t=two", 1))},
+		{"stage count", withParams(strings.Replace(params, "n=3", "n=11", 1))},
+		{"time cost", withParams(strings.Replace(params, "t=2", "t=29", 1))},
+		{"zero time cost", withParams(strings.Replace(params, "t=2", "t=0", 1))},
+		{"memory cost", withParams(strings.Replace(params, "m=1024", "m=1048577", 1))},
+		{"negative memory cost", withParams(strings.Replace(params, "m=1024", "m=-1", 1))},
+		{"parallelism", withParams(strings.Replace(params, "p=2", "p=65", 1))},
+		{"lanes", withParams(strings.Replace(params, "l=3", "l=65", 1))},
+		{"integrator count", withParams(strings.Replace(params, "i=rk4-euler-dopri5", "i=rk4-euler", 1))},
+		{"system count", withParams(strings.Replace(params, "c=lorenz-rossler-lorenz", "c=lorenz", 1))},
+	}
+	for _, tt := range tests {
+		if _, err := ParsePHC(tt.encoded); err == nil {
+			t.Errorf("%s: %s accepted", tt.name, tt.encoded)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+// =======================
+// qhash/engine.go
+// =======================
+
+package qhash
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+)
+
+// Trajectory describes the computation of one stage: a trajectory of System
+// from State folded into OutSize bytes.
+type Trajectory struct {
+	System     string // "" selects DefaultSystem
+	State      []*big.Float
+	Params     []*big.Float // in the layout of the system's DefaultParams
+	Dt         *big.Float
+	Integrator string // "" selects DefaultIntegrator
+	Iterations int
+	Discard    int // warm-up steps before extraction
+	Substeps   int // integrator steps of Dt/Substeps per step; 0 means 1
+	OutSize    int // bytes
+}
+
+// Engine evolves trajectories with a particular number representation.
+// Engines are deterministic but generally not bit-compatible with each other,
+// so the engine is recorded with every hardened hash.
+type Engine interface {
+	Name() string
+	// Supports reports whether the engine implements the named system and
+	// integrator.
+	Supports(system, integrator string) bool
+	Run(ctx context.Context, t Trajectory) ([]byte, error)
+}
+
+// streamer is implemented by the built-in engines. stream returns the
+// bytes extracted from t at shifts on every step, before folding.
+type streamer interface {
+	stream(ctx context.Context, t Trajectory, shifts []int) ([]byte, error)
+}
+
+var engines = map[string]Engine{
+	EngineBigFloat: BigFloatEngine{},
+	EngineFixed:    FixedEngine{},
+	EngineFloat64:  Float64Engine{},
+}
+
+// LookupEngine returns the engine registered under name. An empty name
+// selects EngineBigFloat.
+func LookupEngine(name string) (Engine, error) {
+	if name == "" {
+		name = EngineBigFloat
+	}
+	e, ok := engines[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown engine: %s", name)
+	}
+	return e, nil
+}
+
+// validateEngine checks that engine exists and supports the per-stage
+// systems and integrators. Either list may be nil, selecting the defaults.
+func validateEngine(engine string, systems, integrators []string) error {
+	e, err := LookupEngine(engine)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(systems) || i < len(integrators); i++ {
+		system, integrator := DefaultSystem, DefaultIntegrator
+		if i < len(systems) && systems[i] != "" {
+			system = systems[i]
+		}
+		if i < len(integrators) && integrators[i] != "" {
+			integrator = integrators[i]
+		}
+		if !e.Supports(system, integrator) {
+			return fmt.Errorf("stage %d: %s with %s not supported by %s engine",
+				i, system, integrator, e.Name())
+		}
+	}
+	return nil
+}
+
+// eulerOnly reports whether system and integrator are Lorenz or the 4D
+// hyperchaotic Lorenz system with Euler, the combinations the specialised
+// engines implement.
+func eulerOnly(system, integrator string) bool {
+	return (system == "" || system == SystemLorenz || system == SystemHyperLorenz) &&
+		(integrator == "" || integrator == DefaultIntegrator)
+}
+
+// eulerArgs checks that t is supported by the specialised engines and
+// returns its system and its dt scaled by the system's TimeScale.
+func eulerArgs(t Trajectory) (ChaoticSystem, *big.Float, error) {
+	if !eulerOnly(t.System, t.Integrator) {
+		return nil, nil, fmt.Errorf("only %s and %s with %s are supported",
+			SystemLorenz, SystemHyperLorenz, DefaultIntegrator)
+	}
+	sys, err := LookupSystem(t.System)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(t.State) != sys.Dim() {
+		return nil, nil, fmt.Errorf("%s state has %d coordinates, expected %d",
+			sys.Name(), len(t.State), sys.Dim())
+	}
+	for _, v := range t.State {
+		if v == nil {
+			return nil, nil, fmt.Errorf("nil parameters")
+		}
+	}
+	if err := sys.Validate(t.Params); err != nil {
+		return nil, nil, err
+	}
+	if t.Dt == nil {
+		return nil, nil, fmt.Errorf("nil parameters")
+	}
+	if d, _ := t.Dt.Float64(); d <= 0 || d > 0.1 {
+		return nil, nil, fmt.Errorf("dt parameter out of range: %f", d)
+	}
+	dt := t.Dt
+	if scale := sys.TimeScale(); scale != 1 {
+		dt = new(big.Float).SetPrec(128).Mul(dt, big.NewFloat(scale))
+	}
+	return sys, dt, nil
+}
+
+// BigFloatEngine integrates with 128-bit math/big.Float. It supports every
+// registered system and integrator.
+type BigFloatEngine struct{}
+
+func (BigFloatEngine) Name() string { return EngineBigFloat }
+
+func (BigFloatEngine) Supports(system, integrator string) bool {
+	_, serr := LookupSystem(system)
+	_, ierr := LookupIntegrator(integrator)
+	return serr == nil && ierr == nil
+}
+
+func (BigFloatEngine) Run(ctx context.Context, t Trajectory) ([]byte, error) {
+	sys, err := LookupSystem(t.System)
+	if err != nil {
+		return nil, err
+	}
+	integ, err := LookupIntegrator(t.Integrator)
+	if err != nil {
+		return nil, err
+	}
+	return trajectoryToHashBig(ctx, integ, sys,
+		t.State, t.Params, t.Dt,
+		t.Iterations, t.Discard, t.Substeps, t.OutSize,
+	)
+}
+
+func (BigFloatEngine) stream(ctx context.Context, t Trajectory, shifts []int) ([]byte, error) {
+	sys, err := LookupSystem(t.System)
+	if err != nil {
+		return nil, err
+	}
+	integ, err := LookupIntegrator(t.Integrator)
+	if err != nil {
+		return nil, err
+	}
+	return trajectoryStream(ctx, integ, sys,
+		t.State, t.Params, t.Dt,
+		t.Iterations, t.Discard, t.Substeps, shifts,
+	)
+}
+
+// FixedEngine integrates in Q96 fixed point (see TrajectoryToHashFixed).
+// Only the Lorenz and 4D hyperchaotic Lorenz systems with the Euler
+// integrator are specified.
+type FixedEngine struct{}
+
+func (FixedEngine) Name() string { return EngineFixed }
+
+func (FixedEngine) Supports(system, integrator string) bool {
+	return eulerOnly(system, integrator)
+}
+
+func (FixedEngine) Run(ctx context.Context, t Trajectory) ([]byte, error) {
+	sys, dt, err := eulerArgs(t)
+	if err != nil {
+		return nil, err
+	}
+	return trajectoryToHashFixed(ctx, sys.Name(),
+		toFixedAll(t.State), toFixedAll(t.Params), ToFixed(dt),
+		t.Iterations, t.Discard, t.Substeps, t.OutSize,
+	)
+}
+
+func (FixedEngine) stream(ctx context.Context, t Trajectory, shifts []int) ([]byte, error) {
+	sys, dt, err := eulerArgs(t)
+	if err != nil {
+		return nil, err
+	}
+	return trajectoryStreamFixed(ctx, sys.Name(),
+		toFixedAll(t.State), toFixedAll(t.Params), ToFixed(dt),
+		t.Iterations, t.Discard, t.Substeps, shifts,
+	)
+}
+
+func toFixedAll(vs []*big.Float) []*big.Int {
+	out := make([]*big.Int, len(vs))
+	for i, v := range vs {
+		out[i] = ToFixed(v)
+	}
+	return out
+}
@@ -0,0 +1,202 @@
+// =======================
+// qhash/fixed.go
+// =======================
+
+package qhash
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+)
+
+// FixedFracBits is the number of fractional bits of the fixed-point engine.
+// A value v is represented by the integer floor(v * 2^FixedFracBits).
+const FixedFracBits = 96
+
+var (
+	fixedOne   = new(big.Int).Lsh(big.NewInt(1), FixedFracBits)
+	fixedLimit = new(big.Int).Mul(big.NewInt(1e10), fixedOne) // overflow bound
+)
+
+// ToFixed converts f to fixed point, rounding toward negative infinity.
+func ToFixed(f *big.Float) *big.Int {
+	scaled := new(big.Float).SetMantExp(f, FixedFracBits)
+	n, acc := scaled.Int(nil) // truncates toward zero
+	if acc == big.Above {
+		n.Sub(n, big.NewInt(1))
+	}
+	return n
+}
+
+// fixedMul returns floor(a*b / 2^FixedFracBits). big.Int's Rsh is an
+// arithmetic shift, so negative products also round toward negative infinity.
+func fixedMul(a, b *big.Int) *big.Int {
+	p := new(big.Int).Mul(a, b)
+	return p.Rsh(p, FixedFracBits)
+}
+
+// fixedSteps holds the fixed-point forward-Euler step of every system the
+// engine implements. Every product is floored immediately; sums and
+// differences are exact. Steps update s in place.
+var fixedSteps = map[string]func(s, p []*big.Int, dt *big.Int){
+	SystemLorenz:      fixedLorenzStep,
+	SystemHyperLorenz: fixedHyperLorenzStep,
+}
+
+func fixedLorenzStep(s, p []*big.Int, dt *big.Int) {
+	x, y, z := s[0], s[1], s[2]
+	sigma, rho, beta := p[0], p[1], p[2]
+
+	dx := fixedMul(sigma, new(big.Int).Sub(y, x))
+	dy := new(big.Int).Sub(fixedMul(x, new(big.Int).Sub(rho, z)), y)
+	dz := new(big.Int).Sub(fixedMul(x, y), fixedMul(beta, z))
+
+	x.Add(x, fixedMul(dx, dt))
+	y.Add(y, fixedMul(dy, dt))
+	z.Add(z, fixedMul(dz, dt))
+}
+
+func fixedHyperLorenzStep(s, p []*big.Int, dt *big.Int) {
+	x, y, z, w := s[0], s[1], s[2], s[3]
+	a, b, c, r := p[0], p[1], p[2], p[3]
+
+	dx := new(big.Int).Add(fixedMul(a, new(big.Int).Sub(y, x)), w)
+	dy := new(big.Int).Sub(fixedMul(c, x), y)
+	dy.Sub(dy, fixedMul(x, z))
+	dz := new(big.Int).Sub(fixedMul(x, y), fixedMul(b, z))
+	dw := new(big.Int).Sub(fixedMul(r, w), fixedMul(y, z))
+
+	x.Add(x, fixedMul(dx, dt))
+	y.Add(y, fixedMul(dy, dt))
+	z.Add(z, fixedMul(dz, dt))
+	w.Add(w, fixedMul(dw, dt))
+}
+
+// fixedDiscretize returns the top byte of the fractional part of v*2^shift,
+// taking the fractional part in [0,1) for negative values as well.
+func fixedDiscretize(v *big.Int, shift int) byte {
+	w := new(big.Int).Lsh(v, uint(shift))
+	w.Mod(w, fixedOne) // Euclidean: always non-negative
+	return byte(w.Rsh(w, FixedFracBits-8).Uint64())
+}
+
+// TrajectoryToHashFixed is the fixed-point counterpart of TrajectoryToHashBig.
+// All arguments are fixed-point integers (see ToFixed); the result is
+// bit-exact on every platform and reproducible with plain integer arithmetic.
+func TrajectoryToHashFixed(
+	x0, y0, z0 *big.Int,
+	sigma, rho, beta, dt *big.Int,
+	iterations, discard, outSize int,
+) ([]byte, error) {
+	if x0 == nil || y0 == nil || z0 == nil || sigma == nil || rho == nil || beta == nil || dt == nil {
+		return nil, fmt.Errorf("nil parameters")
+	}
+
+	hundred := new(big.Int).Mul(big.NewInt(100), fixedOne)
+	for i, p := range []*big.Int{sigma, rho, beta} {
+		if p.Sign() <= 0 || p.Cmp(hundred) > 0 {
+			return nil, fmt.Errorf("%s parameter out of range", []string{"sigma", "rho", "beta"}[i])
+		}
+	}
+
+	return trajectoryToHashFixed(context.Background(), SystemLorenz,
+		[]*big.Int{x0, y0, z0}, []*big.Int{sigma, rho, beta}, dt,
+		iterations, discard, 1, outSize)
+}
+
+// trajectoryToHashFixed is TrajectoryToHashFixed for any system in
+// fixedSteps, with substeps steps of dt/substeps per extracted step and ctx
+// checked on every step. The parameters must already be validated.
+func trajectoryToHashFixed(
+	ctx context.Context,
+	system string,
+	state0, params []*big.Int,
+	dt *big.Int,
+	iterations, discard, substeps, outSize int,
+) ([]byte, error) {
+	if outSize <= 0 || outSize > 128 {
+		return nil, fmt.Errorf("invalid output size: %d", outSize)
+	}
+
+	stream, err := trajectoryStreamFixed(ctx, system, state0, params, dt,
+		iterations, discard, substeps, extractionShifts(outSize))
+	if err != nil {
+		return nil, err
+	}
+	return foldStream(stream, outSize)
+}
+
+// trajectoryStreamFixed is the fixed-point counterpart of trajectoryStream.
+func trajectoryStreamFixed(
+	ctx context.Context,
+	system string,
+	state0, params []*big.Int,
+	dt *big.Int,
+	iterations, discard, substeps int,
+	shifts []int,
+) ([]byte, error) {
+	step1, ok := fixedSteps[system]
+	if !ok {
+		return nil, fmt.Errorf("%s not supported by %s engine", system, EngineFixed)
+	}
+
+	if iterations < MinIterations || iterations > MaxIterations {
+		return nil, fmt.Errorf("iterations out of safe range: %d", iterations)
+	}
+
+	if dt.Sign() <= 0 || dt.Cmp(new(big.Int).Quo(fixedOne, big.NewInt(10))) > 0 {
+		return nil, fmt.Errorf("dt parameter out of range")
+	}
+
+	if substeps < 1 {
+		substeps = 1
+	}
+	if substeps > 1 {
+		dt = new(big.Int).Quo(dt, big.NewInt(int64(substeps))) // dt > 0: floor
+	}
+
+	state := make([]*big.Int, len(state0))
+	for i, v := range state0 {
+		state[i] = new(big.Int).Set(v)
+	}
+	step := func() error {
+		for k := 0; k < substeps; k++ {
+			step1(state, params, dt)
+			for i, v := range state {
+				if new(big.Int).Abs(v).Cmp(fixedLimit) > 0 {
+					return fmt.Errorf("%c %w", coordNames[i], ErrOverflow)
+				}
+			}
+		}
+		return nil
+	}
+
+	for i := 0; i < discard; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := step(); err != nil {
+			return nil, fmt.Errorf("warm-up step %d failed: %w", i, err)
+		}
+	}
+
+	stream := make([]byte, 0, iterations*len(state)*len(shifts))
+
+	for i := 0; i < iterations; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := step(); err != nil {
+			return nil, fmt.Errorf("iteration %d failed: %w", i, err)
+		}
+
+		for _, shift := range shifts {
+			for _, v := range state {
+				stream = append(stream, fixedDiscretize(v, shift))
+			}
+		}
+	}
+
+	return stream, nil
+}
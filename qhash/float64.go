@@ -0,0 +1,223 @@
+// =======================
+// qhash/float64.go
+// =======================
+
+package qhash
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// float64CtxInterval is how many steps the float64 engine runs between
+// context checks; a check per step would dominate its cost.
+const float64CtxInterval = 1024
+
+// Float64Engine integrates in IEEE-754 double precision without allocating
+// per step. It trades the 128-bit precision of BigFloatEngine for speed and
+// suits non-password uses such as content fingerprints. Only the Lorenz and
+// 4D hyperchaotic Lorenz systems with the Euler integrator are supported.
+type Float64Engine struct{}
+
+func (Float64Engine) Name() string { return EngineFloat64 }
+
+func (Float64Engine) Supports(system, integrator string) bool {
+	return eulerOnly(system, integrator)
+}
+
+func (Float64Engine) Run(ctx context.Context, t Trajectory) ([]byte, error) {
+	sys, dt, err := eulerArgs(t)
+	if err != nil {
+		return nil, err
+	}
+	d, _ := dt.Float64() // round to nearest even
+	return trajectoryToHashFloat64(ctx, sys.Name(),
+		toFloat64All(t.State), toFloat64All(t.Params), d,
+		t.Iterations, t.Discard, t.Substeps, t.OutSize,
+	)
+}
+
+func (Float64Engine) stream(ctx context.Context, t Trajectory, shifts []int) ([]byte, error) {
+	sys, dt, err := eulerArgs(t)
+	if err != nil {
+		return nil, err
+	}
+	d, _ := dt.Float64()
+	return trajectoryStreamFloat64(ctx, sys.Name(),
+		toFloat64All(t.State), toFloat64All(t.Params), d,
+		t.Iterations, t.Discard, t.Substeps, shifts,
+	)
+}
+
+func toFloat64All(vs []*big.Float) []float64 {
+	out := make([]float64, len(vs))
+	for i, v := range vs {
+		out[i], _ = v.Float64() // round to nearest even
+	}
+	return out
+}
+
+// TrajectoryToHashFloat64 is the float64 counterpart of TrajectoryToHashBig.
+// Every operation is a single correctly rounded IEEE-754 operation in a fixed
+// order, so results are identical on all platforms.
+func TrajectoryToHashFloat64(
+	x0, y0, z0 float64,
+	sigma, rho, beta, dt float64,
+	iterations, discard, outSize int,
+) ([]byte, error) {
+	if !(sigma > 0 && sigma <= 100) {
+		return nil, fmt.Errorf("sigma parameter out of range: %f", sigma)
+	}
+	if !(rho > 0 && rho <= 100) {
+		return nil, fmt.Errorf("rho parameter out of range: %f", rho)
+	}
+	if !(beta > 0 && beta <= 100) {
+		return nil, fmt.Errorf("beta parameter out of range: %f", beta)
+	}
+
+	return trajectoryToHashFloat64(context.Background(), SystemLorenz,
+		[]float64{x0, y0, z0}, []float64{sigma, rho, beta}, dt,
+		iterations, discard, 1, outSize)
+}
+
+// trajectoryToHashFloat64 is TrajectoryToHashFloat64 for any system in
+// float64Steps, with substeps steps of dt/substeps per extracted step and
+// ctx checked every float64CtxInterval steps. The parameters must already
+// be validated.
+func trajectoryToHashFloat64(
+	ctx context.Context,
+	system string,
+	state, params []float64,
+	dt float64,
+	iterations, discard, substeps, outSize int,
+) ([]byte, error) {
+	if outSize <= 0 || outSize > 128 {
+		return nil, fmt.Errorf("invalid output size: %d", outSize)
+	}
+
+	stream, err := trajectoryStreamFloat64(ctx, system, state, params, dt,
+		iterations, discard, substeps, extractionShifts(outSize))
+	if err != nil {
+		return nil, err
+	}
+	return foldStream(stream, outSize)
+}
+
+// trajectoryStreamFloat64 is the float64 counterpart of trajectoryStream.
+func trajectoryStreamFloat64(
+	ctx context.Context,
+	system string,
+	state, params []float64,
+	dt float64,
+	iterations, discard, substeps int,
+	shifts []int,
+) ([]byte, error) {
+	step1, ok := float64Steps[system]
+	if !ok {
+		return nil, fmt.Errorf("%s not supported by %s engine", system, EngineFloat64)
+	}
+
+	if iterations < MinIterations || iterations > MaxIterations {
+		return nil, fmt.Errorf("iterations out of safe range: %d", iterations)
+	}
+
+	if !(dt > 0 && dt <= 0.1) {
+		return nil, fmt.Errorf("dt parameter out of range: %f", dt)
+	}
+
+	if substeps < 1 {
+		substeps = 1
+	}
+	dt /= float64(substeps)
+
+	state = append([]float64(nil), state...)
+	step := func() error {
+		for k := 0; k < substeps; k++ {
+			step1(state, params, dt)
+			for i, v := range state {
+				if !(math.Abs(v) <= 1e10) {
+					return fmt.Errorf("%c %w: %f", coordNames[i], ErrOverflow, v)
+				}
+			}
+		}
+		return nil
+	}
+	for i := 0; i < discard; i++ {
+		if i%float64CtxInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		if err := step(); err != nil {
+			return nil, fmt.Errorf("warm-up step %d failed: %w", i, err)
+		}
+	}
+
+	stream := make([]byte, 0, iterations*len(state)*len(shifts))
+
+	for i := 0; i < iterations; i++ {
+		if i%float64CtxInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		if err := step(); err != nil {
+			return nil, fmt.Errorf("iteration %d failed: %w", i, err)
+		}
+
+		for _, shift := range shifts {
+			for _, v := range state {
+				stream = append(stream, float64Discretize(v, shift))
+			}
+		}
+	}
+
+	return stream, nil
+}
+
+// float64Steps holds the forward-Euler step of every system the engine
+// implements. The explicit float64 conversions round every product before
+// it is added, which forbids the compiler from fusing them into FMA
+// instructions on arm64, ppc64 and s390x. Steps update s in place.
+var float64Steps = map[string]func(s, p []float64, dt float64){
+	SystemLorenz:      float64LorenzStep,
+	SystemHyperLorenz: float64HyperLorenzStep,
+}
+
+func float64LorenzStep(s, p []float64, dt float64) {
+	x, y, z := s[0], s[1], s[2]
+	sigma, rho, beta := p[0], p[1], p[2]
+
+	dx := sigma * (y - x)
+	dy := float64(x*(rho-z)) - y
+	dz := float64(x*y) - float64(beta*z)
+
+	s[0] = x + float64(dx*dt)
+	s[1] = y + float64(dy*dt)
+	s[2] = z + float64(dz*dt)
+}
+
+func float64HyperLorenzStep(s, p []float64, dt float64) {
+	x, y, z, w := s[0], s[1], s[2], s[3]
+	a, b, c, r := p[0], p[1], p[2], p[3]
+
+	dx := float64(a*(y-x)) + w
+	dy := float64(c*x) - y - float64(x*z)
+	dz := float64(x*y) - float64(b*z)
+	dw := float64(r*w) - float64(y*z)
+
+	s[0] = x + float64(dx*dt)
+	s[1] = y + float64(dy*dt)
+	s[2] = z + float64(dz*dt)
+	s[3] = w + float64(dw*dt)
+}
+
+// float64Discretize returns the top byte of the fractional part of v*2^shift,
+// taking the fractional part in [0,1) for negative values as well. Scaling
+// by powers of two and subtracting the floor are exact.
+func float64Discretize(v float64, shift int) byte {
+	w := math.Ldexp(v, shift)
+	return byte((w - math.Floor(w)) * 256)
+}
@@ -0,0 +1,196 @@
+// =======================
+// qhash/integrator.go
+// =======================
+
+package qhash
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Derivative returns d(state)/dt for an autonomous system of ODEs.
+type Derivative func(state []*big.Float) []*big.Float
+
+// Integrator advances a state by one output step of size dt in place.
+type Integrator interface {
+	Name() string
+	Step(state []*big.Float, f Derivative, dt *big.Float) error
+}
+
+// DefaultIntegrator is used for stages that do not select one.
+const DefaultIntegrator = "euler"
+
+var integrators = map[string]Integrator{
+	"euler":  Euler{},
+	"rk4":    RK4{},
+	"dopri5": DormandPrince{},
+}
+
+// LookupIntegrator returns the integrator registered under name. An empty
+// name selects DefaultIntegrator.
+func LookupIntegrator(name string) (Integrator, error) {
+	if name == "" {
+		name = DefaultIntegrator
+	}
+	integ, ok := integrators[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown integrator: %s", name)
+	}
+	return integ, nil
+}
+
+// Euler is the explicit forward-Euler scheme QHASH has always used.
+type Euler struct{}
+
+func (Euler) Name() string { return "euler" }
+
+func (Euler) Step(state []*big.Float, f Derivative, dt *big.Float) error {
+	d := f(state)
+	for i := range state {
+		state[i].Add(state[i], new(big.Float).Mul(d[i], dt))
+	}
+	return nil
+}
+
+// RK4 is the classic fourth-order Runge-Kutta scheme.
+type RK4 struct{}
+
+func (RK4) Name() string { return "rk4" }
+
+func (RK4) Step(state []*big.Float, f Derivative, dt *big.Float) error {
+	half := new(big.Float).Quo(dt, big.NewFloat(2))
+
+	k1 := f(state)
+	k2 := f(offsetState(state, half, []*big.Float{one}, k1))
+	k3 := f(offsetState(state, half, []*big.Float{one}, k2))
+	k4 := f(offsetState(state, dt, []*big.Float{one}, k3))
+
+	sixth := new(big.Float).Quo(dt, big.NewFloat(6))
+	next := offsetState(state, sixth, []*big.Float{one, two, two, one}, k1, k2, k3, k4)
+	for i := range state {
+		state[i].Set(next[i])
+	}
+	return nil
+}
+
+// DormandPrince is the adaptive Dormand-Prince 5(4) scheme. Each output step
+// is covered by dyadic substeps dt/2^k chosen by embedded error control, so
+// step-size decisions are exact comparisons and fully deterministic.
+type DormandPrince struct{}
+
+func (DormandPrince) Name() string { return "dopri5" }
+
+const (
+	dopriMaxDepth = 16 // smallest substep is dt/2^16
+	dopriRelTol   = 1e-9
+	dopriAbsTol   = 1e-12
+)
+
+var (
+	one = big.NewFloat(1)
+	two = big.NewFloat(2)
+
+	dopriA = [][]*big.Float{
+		nil,
+		{rat(1, 5)},
+		{rat(3, 40), rat(9, 40)},
+		{rat(44, 45), rat(-56, 15), rat(32, 9)},
+		{rat(19372, 6561), rat(-25360, 2187), rat(64448, 6561), rat(-212, 729)},
+		{rat(9017, 3168), rat(-355, 33), rat(46732, 5247), rat(49, 176), rat(-5103, 18656)},
+		{rat(35, 384), rat(0, 1), rat(500, 1113), rat(125, 192), rat(-2187, 6784), rat(11, 84)},
+	}
+
+	// Difference between the fifth- and fourth-order weights
+	dopriE = []*big.Float{
+		rat(71, 57600), rat(0, 1), rat(-71, 16695), rat(71, 1920),
+		rat(-17253, 339200), rat(22, 525), rat(-1, 40),
+	}
+
+	dopriRelTolF = big.NewFloat(dopriRelTol).SetPrec(128)
+	dopriAbsTolF = big.NewFloat(dopriAbsTol).SetPrec(128)
+)
+
+func (DormandPrince) Step(state []*big.Float, f Derivative, dt *big.Float) error {
+	const total = 1 << dopriMaxDepth
+	depth, pos := 0, 0
+
+	for pos < total {
+		h := new(big.Float).SetMantExp(dt, -depth)
+
+		// Seven stages; the last one is the fifth-order solution (FSAL)
+		k := make([][]*big.Float, 0, 7)
+		k = append(k, f(state))
+		for s := 1; s < 7; s++ {
+			k = append(k, f(offsetState(state, h, dopriA[s], k...)))
+		}
+		next := offsetState(state, h, dopriA[6], k[:6]...)
+		errEst := offsetState(zeroState(len(state)), h, dopriE, k...)
+
+		accept, grow := true, true
+		for i := range state {
+			scale := new(big.Float).Abs(state[i])
+			if a := new(big.Float).Abs(next[i]); a.Cmp(scale) > 0 {
+				scale = a
+			}
+			tol := new(big.Float).Mul(scale, dopriRelTolF)
+			tol.Add(tol, dopriAbsTolF)
+
+			e := new(big.Float).Abs(errEst[i])
+			if e.Cmp(tol) > 0 {
+				accept = false
+			}
+			// Halving the step shrinks a fifth-order error about 32x
+			if new(big.Float).Mul(e, big.NewFloat(32)).Cmp(tol) > 0 {
+				grow = false
+			}
+		}
+
+		if !accept {
+			if depth == dopriMaxDepth {
+				return fmt.Errorf("dopri5 step size underflow")
+			}
+			depth++
+			continue
+		}
+
+		for i := range state {
+			state[i].Set(next[i])
+		}
+		pos += total >> depth
+
+		// Only grow when the next, larger substep stays aligned
+		if grow && depth > 0 && pos%(total>>(depth-1)) == 0 {
+			depth--
+		}
+	}
+	return nil
+}
+
+// offsetState returns state + h * sum(coeffs[j] * ks[j]).
+func offsetState(state []*big.Float, h *big.Float, coeffs []*big.Float, ks ...[]*big.Float) []*big.Float {
+	out := make([]*big.Float, len(state))
+	for i := range state {
+		sum := new(big.Float).SetPrec(state[i].Prec())
+		for j, c := range coeffs {
+			if c.Sign() == 0 {
+				continue
+			}
+			sum.Add(sum, new(big.Float).Mul(c, ks[j][i]))
+		}
+		out[i] = new(big.Float).Add(state[i], sum.Mul(sum, h))
+	}
+	return out
+}
+
+func zeroState(n int) []*big.Float {
+	out := make([]*big.Float, n)
+	for i := range out {
+		out[i] = new(big.Float).SetPrec(128)
+	}
+	return out
+}
+
+func rat(a, b int64) *big.Float {
+	return new(big.Float).SetPrec(128).SetRat(big.NewRat(a, b))
+}
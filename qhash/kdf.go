@@ -0,0 +1,96 @@
+// =======================
+// qhash/kdf.go
+// =======================
+
+package qhash
+
+import "fmt"
+
+// KDFHashSize is the digest size of the HMAC used by DeriveKey.
+const KDFHashSize = 512
+
+// MinKDFSaltSize is the shortest salt DerivePasswordKey accepts.
+const MinKDFSaltSize = 16
+
+// DeriveKey derives length bytes from secret with HKDF (RFC 5869) over
+// HMAC-QHASH-512: salt extracts a pseudorandom key from secret, which is
+// then expanded with the context string info. A nil salt is treated as
+// KDFHashSize/8 zero bytes. length is at most 255*KDFHashSize/8.
+//
+// DeriveKey suits high-entropy secrets such as key-exchange outputs; use
+// DerivePasswordKey for passwords.
+func DeriveKey(secret, salt, info []byte, length int) ([]byte, error) {
+	if err := checkKeyLength(length); err != nil {
+		return nil, err
+	}
+	if len(salt) == 0 {
+		salt = make([]byte, KDFHashSize/8)
+	}
+
+	// Extract
+	mac, err := NewMAC(salt, KDFHashSize)
+	if err != nil {
+		return nil, err
+	}
+	mac.Write(secret)
+
+	return expandKey(mac.Sum(nil), info, length)
+}
+
+// DerivePasswordKey is DeriveKey for low-entropy passwords. The extract step
+// is the hardened computation with params' time, memory and parallelism
+// costs, keyed by salt; the expand step is the same as DeriveKey's.
+func DerivePasswordKey(password, salt, info []byte, length int, params PasswordParams) ([]byte, error) {
+	if err := checkKeyLength(length); err != nil {
+		return nil, err
+	}
+	if len(salt) < MinKDFSaltSize {
+		return nil, fmt.Errorf("salt must be at least %d bytes", MinKDFSaltSize)
+	}
+	if params.MemoryCost <= 0 {
+		return nil, fmt.Errorf("password key derivation requires a memory cost")
+	}
+
+	h, err := params.hasher(WithKey(salt))
+	if err != nil {
+		return nil, fmt.Errorf("invalid password parameters: %w", err)
+	}
+	prk, err := h.Hash(password)
+	if err != nil {
+		return nil, err
+	}
+	return expandKey(prk, info, length)
+}
+
+// expandKey is HKDF-Expand: T(i) = HMAC(prk, T(i-1) || info || i). One
+// HMAC is reset for every block, so a block costs the two plain digest
+// computations of a tag.
+func expandKey(prk, info []byte, length int) ([]byte, error) {
+	if err := checkKeyLength(length); err != nil {
+		return nil, err
+	}
+
+	mac, err := NewMAC(prk, KDFHashSize)
+	if err != nil {
+		return nil, err
+	}
+	var t, out []byte
+	for i := 1; len(out) < length; i++ {
+		mac.Reset()
+		mac.Write(t)
+		mac.Write(info)
+		mac.Write([]byte{byte(i)})
+		t = mac.Sum(nil)
+		out = append(out, t...)
+	}
+	return out[:length], nil
+}
+
+// checkKeyLength rejects lengths HKDF-Expand cannot produce, before the
+// extract step spends any work.
+func checkKeyLength(length int) error {
+	if length <= 0 || length > 255*KDFHashSize/8 {
+		return fmt.Errorf("invalid key length: %d", length)
+	}
+	return nil
+}
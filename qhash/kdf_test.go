@@ -0,0 +1,97 @@
+// =======================
+// qhash/kdf_test.go
+// =======================
+
+package qhash
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+var kdfTestParams = PasswordParams{HashSize: 256, TimeCost: 1, MemoryCost: 64, Parallelism: 1}
+
+func TestDeriveKeyKnownAnswer(t *testing.T) {
+	const want = "ea9f77dd55538afc91604358135813ecb378bb92148ef3a08a98d054f3c019dd" +
+		"264a8d52079ab705314fc27b68e253c08e45f6fed5ff15e65c05ac5ccaba2b7f" +
+		"403f54ba3904ed60b663780e02b8e3ee"
+	got, err := DeriveKey([]byte("input key material"), []byte("salt"), []byte("qhash test"), 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(got) != want {
+		t.Errorf("key %x, want %s", got, want)
+	}
+
+	// Shorter outputs are prefixes of longer ones
+	short, err := DeriveKey([]byte("input key material"), []byte("salt"), []byte("qhash test"), 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(short, got[:20]) {
+		t.Errorf("20-byte key %x is not a prefix of %x", short, got)
+	}
+}
+
+func TestDerivePasswordKeyKnownAnswer(t *testing.T) {
+	const want = "488830a8d4555c0e11127cee7997784e76c59d2fcc4d06b3b79db65ebc76e3a4"
+	got, err := DerivePasswordKey([]byte("password"), []byte("0123456789abcdef"),
+		[]byte("qhash test"), 32, kdfTestParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(got) != want {
+		t.Errorf("key %x, want %s", got, want)
+	}
+}
+
+func TestDeriveKeyInputs(t *testing.T) {
+	derive := func(salt, info string) []byte {
+		t.Helper()
+		k, err := DeriveKey([]byte("secret"), []byte(salt), []byte(info), 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	base := derive("salt", "info")
+	if bytes.Equal(base, derive("salt", "other info")) {
+		t.Error("info does not change the key")
+	}
+	if bytes.Equal(base, derive("other salt", "info")) {
+		t.Error("salt does not change the key")
+	}
+
+	// A nil salt is KDFHashSize/8 zero bytes
+	if !bytes.Equal(derive("", "info"), derive(string(make([]byte, KDFHashSize/8)), "info")) {
+		t.Error("empty salt differs from the zero salt")
+	}
+}
+
+func TestDeriveKeyLength(t *testing.T) {
+	for _, n := range []int{0, -1, 255*KDFHashSize/8 + 1} {
+		if _, err := DeriveKey([]byte("secret"), nil, nil, n); err == nil {
+			t.Errorf("length %d accepted", n)
+		}
+	}
+}
+
+func TestDerivePasswordKeyRejects(t *testing.T) {
+	if _, err := DerivePasswordKey([]byte("password"), make([]byte, MinKDFSaltSize-1),
+		nil, 32, kdfTestParams); err == nil {
+		t.Error("short salt accepted")
+	}
+
+	params := kdfTestParams
+	params.MemoryCost = 0
+	if _, err := DerivePasswordKey([]byte("password"), make([]byte, MinKDFSaltSize),
+		nil, 32, params); err == nil {
+		t.Error("zero memory cost accepted")
+	}
+
+	if _, err := DerivePasswordKey([]byte("password"), make([]byte, MinKDFSaltSize),
+		nil, 0, kdfTestParams); err == nil {
+		t.Error("zero length accepted")
+	}
+}
@@ -5,92 +5,267 @@
 package qhash
 
 import (
-	"bytes"
+	"context"
 	"crypto/sha256"
 	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"math/big"
-	"runtime"
+	"sync"
 	"time"
 )
 
-func NewHardenedLorenzHasher(hashSize int) (*HardenedLorenzHasher, error) {
+func NewHardenedLorenzHasher(hashSize int, opts ...Option) (*HardenedLorenzHasher, error) {
+	return newHasher(hashSize, defaultStages(HashSize(hashSize)), opts)
+}
+
+// maxSubsteps bounds the refinement of overflowing stage trajectories.
+const maxSubsteps = 8
+
+// newHasher creates a hasher running stages, applies opts and validates the
+// result.
+func newHasher(hashSize int, stages []LorenzStage, opts []Option) (*HardenedLorenzHasher, error) {
 	size := HashSize(hashSize)
 	if size != Size256 && size != Size384 && size != Size512 && size != Size1024 {
 		return nil, fmt.Errorf("unsupported hash size: %d. Supported: 256, 384, 512, 1024", hashSize)
 	}
 
+	stageMap := make(map[HashSize][]LorenzStage)
+	stageMap[size] = stages
+
+	h := &HardenedLorenzHasher{
+		stages:         stageMap,
+		memoryHardness: DefaultMemoryHardness,
+		minComputeTime: MinComputeTime,
+		hashSize:       size,
+		timeCost:       1,
+		parallelism:    1,
+		lanes:          1,
+		clock:          time.Now,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	// Validate stage parameters
+	for i, stage := range stages {
+		if stage.Iterations < MinIterations || stage.Iterations > MaxIterations {
+			return nil, fmt.Errorf("stage %d iterations out of safe range", i)
+		}
+		if stage.Dt == nil {
+			return nil, fmt.Errorf("stage %d dt parameter invalid", i)
+		}
+		if dt, _ := stage.Dt.Float64(); dt <= 0 || dt > 0.1 {
+			return nil, fmt.Errorf("stage %d dt parameter invalid", i)
+		}
+		if _, err := LookupIntegrator(stage.Integrator); err != nil {
+			return nil, fmt.Errorf("stage %d: %w", i, err)
+		}
+		sys, err := LookupSystem(stage.System)
+		if err != nil {
+			return nil, fmt.Errorf("stage %d: %w", i, err)
+		}
+		// Ensure system parameters are reasonable
+		if err := sys.Validate(stageParams(stage, sys)); err != nil {
+			return nil, fmt.Errorf("stage %d: %w", i, err)
+		}
+	}
+
+	if err := h.cost().validate(stages); err != nil {
+		return nil, err
+	}
+	if err := validateEngine(h.engine, stageSystems(stages), stageIntegrators(stages)); err != nil {
+		return nil, err
+	}
+	if h.engine == EngineBigFloat {
+		h.engine = ""
+	}
+	return h, nil
+}
+
+// stageParams returns the parameters st runs sys with.
+func stageParams(st LorenzStage, sys ChaoticSystem) []*big.Float {
+	if sys.Name() == SystemLorenz {
+		if st.Sigma == nil {
+			return sys.DefaultParams()
+		}
+		return []*big.Float{st.Sigma, st.Rho, st.Beta}
+	}
+	if st.Params != nil && st.System == sys.Name() {
+		return st.Params
+	}
+	return sys.DefaultParams()
+}
+
+// defaultStages returns the built-in stage table for size.
+func defaultStages(size HashSize) []LorenzStage {
 	f := func(v float64) *big.Float { return big.NewFloat(v).SetPrec(128) }
+	st := func(sigma, rho, beta, dt float64, iterations, id int, desc string) LorenzStage {
+		return LorenzStage{
+			Sigma: f(sigma), Rho: f(rho), Beta: f(beta), Dt: f(dt),
+			Iterations: iterations, StageID: id, Description: desc,
+		}
+	}
 
 	// Define stages for each hash size
 	stageConfigs := map[HashSize][]LorenzStage{
 		Size256: {
-			{f(10), f(28), f(8.0 / 3.0), f(0.01), 2000, 1, "Classic-256"},
-			{f(16), f(45.6), f(4), f(0.008), 3000, 2, "Energetic-256"},
+			st(10, 28, 8.0/3.0, 0.01, 2000, 1, "Classic-256"),
+			st(16, 45.6, 4, 0.008, 3000, 2, "Energetic-256"),
 		},
 		Size384: {
-			{f(10), f(28), f(8.0 / 3.0), f(0.01), 2000, 1, "Classic-384"},
-			{f(16), f(45.6), f(4), f(0.008), 3000, 2, "Energetic-384"},
-			{f(12.5), f(35.2), f(2.5), f(0.012), 2500, 3, "Wide-384"},
+			st(10, 28, 8.0/3.0, 0.01, 2000, 1, "Classic-384"),
+			st(16, 45.6, 4, 0.008, 3000, 2, "Energetic-384"),
+			st(12.5, 35.2, 2.5, 0.012, 2500, 3, "Wide-384"),
 		},
 		Size512: {
-			{f(10), f(28), f(8.0 / 3.0), f(0.01), 2000, 1, "Classic-512"},
-			{f(16), f(45.6), f(4), f(0.008), 3000, 2, "Energetic-512"},
-			{f(12.5), f(35.2), f(2.5), f(0.012), 2500, 3, "Wide-512"},
-			{f(8.5), f(24.8), f(6.2), f(0.015), 1800, 4, "Compact-512"},
+			st(10, 28, 8.0/3.0, 0.01, 2000, 1, "Classic-512"),
+			st(16, 45.6, 4, 0.008, 3000, 2, "Energetic-512"),
+			st(12.5, 35.2, 2.5, 0.012, 2500, 3, "Wide-512"),
+			st(8.5, 24.8, 6.2, 0.015, 1800, 4, "Compact-512"),
 		},
 		Size1024: {
-			{f(10), f(28), f(8.0 / 3.0), f(0.01), 2000, 1, "Classic-1024"},
-			{f(16), f(45.6), f(4), f(0.008), 3000, 2, "Energetic-1024"},
-			{f(12.5), f(35.2), f(2.5), f(0.012), 2500, 3, "Wide-1024"},
-			{f(8.5), f(24.8), f(6.2), f(0.015), 1800, 4, "Compact-1024"},
-			{f(14.2), f(32.1), f(3.8), f(0.009), 3200, 5, "Extended-1-1024"},
-			{f(11.7), f(41.3), f(5.1), f(0.011), 2800, 6, "Extended-2-1024"},
-			{f(9.3), f(26.7), f(7.4), f(0.013), 2200, 7, "Extended-3-1024"},
-			{f(13.8), f(38.9), f(2.9), f(0.007), 3500, 8, "Extended-4-1024"},
+			st(10, 28, 8.0/3.0, 0.01, 2000, 1, "Classic-1024"),
+			st(16, 45.6, 4, 0.008, 3000, 2, "Energetic-1024"),
+			st(12.5, 35.2, 2.5, 0.012, 2500, 3, "Wide-1024"),
+			st(8.5, 24.8, 6.2, 0.015, 1800, 4, "Compact-1024"),
+			st(14.2, 32.1, 3.8, 0.009, 3200, 5, "Extended-1-1024"),
+			st(11.7, 41.3, 5.1, 0.011, 2800, 6, "Extended-2-1024"),
+			st(9.3, 26.7, 7.4, 0.013, 2200, 7, "Extended-3-1024"),
+			st(13.8, 38.9, 2.9, 0.007, 3500, 8, "Extended-4-1024"),
 		},
 	}
 
-	stages := stageConfigs[size]
+	return stageConfigs[size]
+}
 
-	// Validate stage parameters
-	for i, stage := range stages {
-		if stage.Iterations < MinIterations || stage.Iterations > MaxIterations {
-			return nil, fmt.Errorf("stage %d iterations out of safe range", i)
-		}
+func (h *HardenedLorenzHasher) GetHashSize() int {
+	return int(h.hashSize)
+}
 
-		// Ensure Lorenz parameters are reasonable
-		if sigma, _ := stage.Sigma.Float64(); sigma <= 0 || sigma > 100 {
-			return nil, fmt.Errorf("stage %d sigma parameter invalid", i)
-		}
-		if rho, _ := stage.Rho.Float64(); rho <= 0 || rho > 100 {
-			return nil, fmt.Errorf("stage %d rho parameter invalid", i)
-		}
-		if beta, _ := stage.Beta.Float64(); beta <= 0 || beta > 100 {
-			return nil, fmt.Errorf("stage %d beta parameter invalid", i)
-		}
-		if dt, _ := stage.Dt.Float64(); dt <= 0 || dt > 0.1 {
-			return nil, fmt.Errorf("stage %d dt parameter invalid", i)
-		}
+// cost returns the hasher's configured work factors.
+func (h *HardenedLorenzHasher) cost() costParams {
+	return costParams{
+		time:        h.timeCost,
+		memory:      h.memoryHardness,
+		parallelism: h.parallelism,
+		lanes:       h.lanes,
 	}
+}
 
-	stageMap := make(map[HashSize][]LorenzStage)
-	stageMap[size] = stages
+// unhardened returns a copy of h without the memory-hard phase and the
+// minimum compute time, for constructions that hash many blocks.
+func (h *HardenedLorenzHasher) unhardened() *HardenedLorenzHasher {
+	c := *h
+	c.memoryHardness = 0
+	c.minComputeTime = 0
+	return &c
+}
 
-	return &HardenedLorenzHasher{
-		stages:         stageMap,
-		memoryHardness: DefaultMemoryHardness,
-		minComputeTime: MinComputeTime,
-		hashSize:       size,
-	}, nil
+// spec returns the compute specification for new hashes of data.
+func (h *HardenedLorenzHasher) spec(data []byte, salt *HierarchicalSalt) computeSpec {
+	return computeSpec{
+		alg:         algorithms[AlgorithmVersion],
+		params:      deriveAdaptiveParameters(data, salt.MasterSalt),
+		cost:        h.cost(),
+		integrators: stageIntegrators(h.stages[h.hashSize]),
+		engine:      h.engine,
+		systems:     stageSystems(h.stages[h.hashSize]),
+	}
 }
 
-func (h *HardenedLorenzHasher) GetHashSize() int {
-	return int(h.hashSize)
+// stageIntegrators lists the integrator of every stage, or nil when all
+// stages use DefaultIntegrator.
+func stageIntegrators(stages []LorenzStage) []string {
+	return perStage(stages, DefaultIntegrator,
+		func(st LorenzStage) string { return st.Integrator })
+}
+
+// stageSystems lists the chaotic system of every stage, or nil when all
+// stages use DefaultSystem.
+func stageSystems(stages []LorenzStage) []string {
+	return perStage(stages, DefaultSystem,
+		func(st LorenzStage) string { return st.System })
+}
+
+// perStage lists field(st) for every stage with "" replaced by def, or nil
+// when every stage uses def.
+func perStage(stages []LorenzStage, def string, field func(LorenzStage) string) []string {
+	names := make([]string, len(stages))
+	custom := false
+	for i, st := range stages {
+		names[i] = field(st)
+		if names[i] == "" {
+			names[i] = def
+		}
+		custom = custom || names[i] != def
+	}
+	if !custom {
+		return nil
+	}
+	return names
+}
+
+// storedCost returns the work factors recorded in stored. Hashes that predate
+// cost recording used a single pass without the memory-hard phase.
+func storedCost(stored *HardenedSaltedHash) costParams {
+	c := costParams{
+		time:        stored.TimeCost,
+		memory:      stored.MemoryCost,
+		parallelism: stored.Parallelism,
+		lanes:       stored.Lanes,
+	}
+	if c.time == 0 {
+		c.time = 1
+	}
+	if c.parallelism == 0 {
+		c.parallelism = 1
+	}
+	if c.lanes == 0 {
+		c.lanes = 1
+	}
+	return c
+}
+
+// validate bounds the work factors so stored hashes cannot request
+// unbounded work.
+func (c costParams) validate(stages []LorenzStage) error {
+	if c.time < 1 || c.time > MaxTimeCost {
+		return fmt.Errorf("time cost out of range: %d", c.time)
+	}
+	for i, st := range stages {
+		if st.Iterations*c.time > MaxIterations {
+			return fmt.Errorf("stage %d iterations exceed limit at time cost %d", i, c.time)
+		}
+	}
+	if c.parallelism < 1 || c.parallelism > MaxParallelism {
+		return fmt.Errorf("parallelism out of range: %d", c.parallelism)
+	}
+	if c.lanes < 1 || c.lanes > MaxLanes {
+		return fmt.Errorf("lanes out of range: %d", c.lanes)
+	}
+	if c.memory < 0 || c.memory > MaxMemoryHardness {
+		return fmt.Errorf("memory cost out of range: %d KiB", c.memory)
+	}
+	if c.memory > 0 && c.memory < c.parallelism {
+		return fmt.Errorf("memory cost %d KiB too small for %d lanes", c.memory, c.parallelism)
+	}
+	return nil
 }
 
 func (h *HardenedLorenzHasher) HashWithHardening(data []byte) (*HardenedSaltedHash, error) {
+	return h.HashWithHardeningContext(context.Background(), data)
+}
+
+// HashWithHardeningContext is HashWithHardening with cancellation. ctx is
+// checked on every Lorenz step, on every memory-hard block and during the
+// enforced minimum compute time.
+func (h *HardenedLorenzHasher) HashWithHardeningContext(
+	ctx context.Context, data []byte,
+) (*HardenedSaltedHash, error) {
 	if len(data) == 0 {
 		return nil, fmt.Errorf("empty data not allowed")
 	}
@@ -100,48 +275,208 @@ func (h *HardenedLorenzHasher) HashWithHardening(data []byte) (*HardenedSaltedHa
 		return nil, fmt.Errorf("salt generation failed: %w", err)
 	}
 
-	params := deriveAdaptiveParameters(data, salt.MasterSalt)
-	return h.compute(data, salt, params)
+	return h.compute(ctx, data, salt, h.spec(data, salt))
 }
 
 func (h *HardenedLorenzHasher) compute(
+	ctx context.Context,
 	data []byte,
 	salt *HierarchicalSalt,
-	params map[string]interface{},
+	spec computeSpec,
 ) (*HardenedSaltedHash, error) {
+	cost := spec.cost
 	start := time.Now()
+	outputSize := int(h.hashSize) / 8 // Convert bits to bytes
+
+	engine, err := LookupEngine(spec.engine)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]laneResult, cost.lanes)
+	if len(results) == 1 {
+		results[0], err = h.runLane(ctx, engine, data, 0, salt, spec)
+	} else {
+		err = h.runLanes(ctx, engine, data, salt, spec, results)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Lanes are merged in order, so the result does not depend on which
+	// goroutine finished first.
 	var checkpoints []TrajectoryCheckpoint
-	buf := make([]byte, len(data))
-	copy(buf, data) // Defensive copy
+	var memSeed, buf []byte
+	for _, r := range results {
+		checkpoints = append(checkpoints, r.checkpoints...)
+		memSeed = append(memSeed, r.memSeed...)
+		buf = append(buf, r.out...)
+	}
+
+	// Memory-hard phase seeded from every stage output
+	if cost.memory > 0 {
+		memSeed = append(memSeed, salt.MasterSalt...)
+		mixed, err := memoryHardMix(ctx, memSeed, cost.memory, cost.parallelism, outputSize)
+		if err != nil {
+			return nil, fmt.Errorf("memory-hard phase failed: %w", err)
+		}
+		buf = mixed
+	}
+
+	// Final quantum-resistant mixing
+	finalHash, err := quantumFinalize(ctx, engine, buf, salt, h.hashSize, spec.alg.version)
+	if err != nil {
+		return nil, fmt.Errorf("quantum finalization failed: %w", err)
+	}
+
+	// Enforce minimum computation time to prevent timing attacks
+	if dt := time.Since(start); dt < h.minComputeTime {
+		timer := time.NewTimer(h.minComputeTime - dt)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return &HardenedSaltedHash{
+		Hash:        finalHash,
+		Salt:        salt,
+		Checkpoints: checkpoints,
+		ComputeTime: time.Since(start).Nanoseconds(),
+		MemoryUsed:  memoryBufferKiB(cost.memory, cost.parallelism),
+		Parameters:  spec.params,
+		Algorithm:   algorithmName(int(h.hashSize)),
+		Version:     spec.alg.version,
+		HashSize:    int(h.hashSize),
+		TimeCost:    cost.time,
+		MemoryCost:  cost.memory,
+		Parallelism: cost.parallelism,
+		Lanes:       cost.recordedLanes(),
+		Integrators: spec.integrators,
+		Engine:      spec.engine,
+		Systems:     spec.systems,
+		Config:      h.config,
+	}, nil
+}
+
+// laneResult is the output of one lane of stages.
+type laneResult struct {
+	checkpoints []TrajectoryCheckpoint
+	memSeed     []byte // every stage output, seeding the memory-hard phase
+	out         []byte // final stage output
+}
+
+// runLanes runs one lane per element of results concurrently. The first
+// failure cancels the other lanes and is returned.
+func (h *HardenedLorenzHasher) runLanes(
+	ctx context.Context, engine Engine, data []byte, salt *HierarchicalSalt,
+	spec computeSpec, results []laneResult,
+) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for l := range results {
+		wg.Add(1)
+		go func(l int) {
+			defer wg.Done()
+			r, err := h.runLane(ctx, engine, laneInput(data, l), l, salt, spec)
+			if err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			results[l] = r
+		}(l)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// laneInput returns the input of lane l when more than one lane runs.
+func laneInput(data []byte, lane int) []byte {
+	in := make([]byte, 0, len(data)+len(laneDomain)+4)
+	in = append(in, data...)
+	in = append(in, laneDomain...)
+	return binary.BigEndian.AppendUint32(in, uint32(lane))
+}
+
+const laneDomain = "QHASH-LANE"
+
+// runLane runs every stage in sequence over input, each stage seeded by the
+// previous stage's output.
+func (h *HardenedLorenzHasher) runLane(
+	ctx context.Context, engine Engine, input []byte, lane int,
+	salt *HierarchicalSalt, spec computeSpec,
+) (laneResult, error) {
+	var r laneResult
+	buf := make([]byte, len(input))
+	copy(buf, input) // Defensive copy
 
 	stages := h.stages[h.hashSize]
-	outputSize := int(h.hashSize) / 8 // Convert bits to bytes
+	outputSize := int(h.hashSize) / 8
 
 	for idx, st := range stages {
 		if idx >= len(salt.StageSalts) {
-			return nil, fmt.Errorf("insufficient stage salts")
+			return r, fmt.Errorf("insufficient stage salts")
 		}
 
 		// Combine with stage salt
 		buf = append(buf, salt.StageSalts[idx]...)
 
+		sys, err := LookupSystem(spec.system(idx))
+		if err != nil {
+			return r, err
+		}
+
 		// Generate initial conditions
-		x0, y0, z0, err := seedBig(buf, salt.MasterSalt)
+		state, err := seedState(buf, salt.MasterSalt, sys.Dim(), sys.Radius())
 		if err != nil {
-			return nil, fmt.Errorf("seed generation failed: %w", err)
+			return r, fmt.Errorf("seed generation failed: %w", err)
 		}
 
-		// Run Lorenz trajectory with size-appropriate parameters
-		iterations := st.Iterations
+		// Run trajectory with size-appropriate parameters
+		iterations := st.Iterations * spec.cost.time
 		discard := 1000 + int(h.hashSize)/4 // More discard for larger sizes
+		if st.Discard > 0 {
+			discard = st.Discard
+		}
 
-		bytesOut, err := TrajectoryToHashBig(
-			x0, y0, z0,
-			st.Sigma, st.Rho, st.Beta, st.Dt,
-			iterations, discard, outputSize,
-		)
+		params, dt := stageParams(st, sys), st.Dt
+		if spec.alg.adaptive {
+			params, dt, iterations = spec.params.apply(sys, params, dt, iterations)
+		}
+		// Lanes share the stage's iterations, down to MinIterations each.
+		if n := spec.cost.lanes; n > 1 {
+			iterations = max(MinIterations, (iterations+n-1)/n)
+		}
+
+		// A trajectory that overflows is rerun with finer integrator steps,
+		// which keeps the output of every trajectory that does not.
+		t := Trajectory{
+			System:     sys.Name(),
+			State:      state,
+			Params:     params,
+			Dt:         dt,
+			Integrator: spec.integrator(idx),
+			Iterations: iterations,
+			Discard:    discard,
+			Substeps:   1,
+			OutSize:    outputSize,
+		}
+		bytesOut, err := engine.Run(ctx, t)
+		for errors.Is(err, ErrOverflow) && t.Substeps < maxSubsteps {
+			t.Substeps *= 2
+			bytesOut, err = engine.Run(ctx, t)
+		}
 		if err != nil {
-			return nil, fmt.Errorf("trajectory computation failed: %w", err)
+			return r, fmt.Errorf("trajectory computation failed: %w", err)
 		}
 
 		// Create checkpoint with appropriate hash function
@@ -163,92 +498,244 @@ func (h *HardenedLorenzHasher) compute(
 			sum = append(h1[:], h2[:]...)
 		}
 
-		checkpoints = append(checkpoints, TrajectoryCheckpoint{
+		cp := TrajectoryCheckpoint{
 			Stage:     idx,
-			Iteration: st.Iterations,
+			Lane:      lane,
+			Iteration: iterations,
 			Hash:      base64.StdEncoding.EncodeToString(sum),
 			Size:      int(h.hashSize),
-		})
+		}
+		if spec.checkpoint != nil && !spec.checkpoint(cp) {
+			return r, errCheckpointMismatch
+		}
+		r.checkpoints = append(r.checkpoints, cp)
 
+		r.memSeed = append(r.memSeed, bytesOut...)
 		buf = bytesOut
 	}
 
-	// Final quantum-resistant mixing
-	finalHash, err := quantumFinalize(buf, salt, h.hashSize)
-	if err != nil {
-		return nil, fmt.Errorf("quantum finalization failed: %w", err)
-	}
+	r.out = buf
+	return r, nil
+}
 
-	// Enforce minimum computation time to prevent timing attacks
-	if dt := time.Since(start); dt < h.minComputeTime {
-		time.Sleep(h.minComputeTime - dt)
+// HashWithSalt runs the hardened computation with a caller-supplied salt
+// hierarchy instead of a freshly generated one. Identical data and salt always
+// produce the same hash.
+func (h *HardenedLorenzHasher) HashWithSalt(
+	data []byte, salt *HierarchicalSalt,
+) (*HardenedSaltedHash, error) {
+	return h.HashWithSaltContext(context.Background(), data, salt)
+}
+
+// HashWithSaltContext is HashWithSalt with cancellation.
+func (h *HardenedLorenzHasher) HashWithSaltContext(
+	ctx context.Context, data []byte, salt *HierarchicalSalt,
+) (*HardenedSaltedHash, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty data not allowed")
+	}
+	if salt == nil || len(salt.MasterSalt) == 0 {
+		return nil, fmt.Errorf("invalid salt")
+	}
+	if salt.HashSize != int(h.hashSize) {
+		return nil, fmt.Errorf("salt size mismatch: expected %d, got %d",
+			int(h.hashSize), salt.HashSize)
 	}
 
-	var m runtime.MemStats
-	runtime.ReadMemStats(&m)
+	return h.compute(ctx, data, salt, h.spec(data, salt))
+}
 
-	return &HardenedSaltedHash{
-		Hash:        finalHash,
-		Salt:        salt,
-		Checkpoints: checkpoints,
-		ComputeTime: time.Since(start).Nanoseconds(),
-		MemoryUsed:  int(m.Alloc / 1024),
-		Parameters:  params,
-		Algorithm:   fmt.Sprintf("QHASH-%d", int(h.hashSize)),
-		Version:     "2.0",
-		HashSize:    int(h.hashSize),
-	}, nil
+// HashDeterministic hashes data with the salt hierarchy derived from key.
+// A nil key selects the unkeyed mode used by Hash.
+func (h *HardenedLorenzHasher) HashDeterministic(data, key []byte) ([]byte, error) {
+	return h.HashDeterministicContext(context.Background(), data, key)
 }
 
-func (h *HardenedLorenzHasher) Hash(data []byte) ([]byte, error) {
-	result, err := h.HashWithHardening(data)
+// HashDeterministicContext is HashDeterministic with cancellation.
+func (h *HardenedLorenzHasher) HashDeterministicContext(
+	ctx context.Context, data, key []byte,
+) ([]byte, error) {
+	salt, err := DeriveSaltHierarchy(key, len(h.stages[h.hashSize]), int(h.hashSize))
+	if err != nil {
+		return nil, fmt.Errorf("salt derivation failed: %w", err)
+	}
+
+	result, err := h.HashWithSaltContext(ctx, data, salt)
 	if err != nil {
 		return nil, err
 	}
 	return result.Hash, nil
 }
 
+// Hash returns the reproducible hash of data, keyed if WithKey was given.
+func (h *HardenedLorenzHasher) Hash(data []byte) ([]byte, error) {
+	return h.HashContext(context.Background(), data)
+}
+
+// HashContext is Hash with cancellation.
+func (h *HardenedLorenzHasher) HashContext(ctx context.Context, data []byte) ([]byte, error) {
+	return h.HashDeterministicContext(ctx, data, h.key)
+}
+
 func (h *HardenedLorenzHasher) VerifyHardenedHash(
 	data []byte, stored *HardenedSaltedHash,
 ) (bool, error) {
+	return h.VerifyHardenedHashContext(context.Background(), data, stored)
+}
+
+// VerifyHardenedHashContext is VerifyHardenedHash with cancellation.
+func (h *HardenedLorenzHasher) VerifyHardenedHashContext(
+	ctx context.Context, data []byte, stored *HardenedSaltedHash,
+) (bool, error) {
+	spec, err := h.verifySpec(data, stored)
+	if err != nil {
+		return false, err
+	}
+
+	recomputed, err := h.compute(ctx, data, stored.Salt, spec)
+	if err != nil {
+		return false, fmt.Errorf("recomputation failed: %w", err)
+	}
+
+	return hashesMatch(recomputed, stored), nil
+}
+
+// VerifyProgressive verifies like VerifyHardenedHashContext. Unless
+// constantTime is set, each stage checkpoint is compared as soon as the
+// stage finishes and the first mismatch returns false without running the
+// remaining stages, the memory-hard phase or the minimum compute time; a
+// match still waits out the minimum compute time. Running time on a
+// mismatch reveals how many stages matched, so pass constantTime
+// for passwords. Hashes without checkpoints, such as decoded $qhash$
+// strings, can only be rejected at the end.
+func (h *HardenedLorenzHasher) VerifyProgressive(
+	ctx context.Context, data []byte, stored *HardenedSaltedHash, constantTime bool,
+) (bool, error) {
+	if constantTime {
+		return h.VerifyHardenedHashContext(ctx, data, stored)
+	}
+
+	spec, err := h.verifySpec(data, stored)
+	if err != nil {
+		return false, err
+	}
+	spec.checkpoint = func(cp TrajectoryCheckpoint) bool {
+		if len(stored.Checkpoints) == 0 {
+			return true
+		}
+		i := cp.Lane*len(h.stages[h.hashSize]) + cp.Stage
+		return i < len(stored.Checkpoints) && cp.Hash == stored.Checkpoints[i].Hash
+	}
+
+	recomputed, err := h.compute(ctx, data, stored.Salt, spec)
+	if errors.Is(err, errCheckpointMismatch) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("recomputation failed: %w", err)
+	}
+
+	return hashesMatch(recomputed, stored), nil
+}
+
+var errCheckpointMismatch = errors.New("checkpoint mismatch")
+
+// verifySpec checks that stored can be verified by h and returns the
+// compute specification that reproduces it.
+func (h *HardenedLorenzHasher) verifySpec(
+	data []byte, stored *HardenedSaltedHash,
+) (computeSpec, error) {
 	if stored == nil || stored.Salt == nil {
-		return false, fmt.Errorf("invalid stored hash")
+		return computeSpec{}, fmt.Errorf("invalid stored hash")
 	}
 
 	// Verify hash size compatibility
 	if stored.HashSize != int(h.hashSize) {
-		return false, fmt.Errorf("hash size mismatch: expected %d, got %d",
+		return computeSpec{}, fmt.Errorf("hash size mismatch: expected %d, got %d",
 			int(h.hashSize), stored.HashSize)
 	}
 
-	// Recompute hash using stored salt
-	params := deriveAdaptiveParameters(data, stored.Salt.MasterSalt)
-	recomputed, err := h.compute(data, stored.Salt, params)
+	if stored.Config != h.config {
+		return computeSpec{}, fmt.Errorf("stage config mismatch: hash uses %q, hasher %q",
+			stored.Config, h.config)
+	}
+
+	// Recompute hash using stored salt and work factors
+	cost := storedCost(stored)
+	if err := cost.validate(h.stages[h.hashSize]); err != nil {
+		return computeSpec{}, fmt.Errorf("invalid stored cost: %w", err)
+	}
+
+	alg, err := lookupAlgorithm(stored.Algorithm, stored.HashSize, stored.Version)
 	if err != nil {
-		return false, fmt.Errorf("recomputation failed: %w", err)
+		return computeSpec{}, err
 	}
 
-	// Compare hashes
-	if !bytes.Equal(recomputed.Hash, stored.Hash) {
-		return false, nil
+	if n := len(stored.Integrators); n != 0 && n != len(h.stages[h.hashSize]) {
+		return computeSpec{}, fmt.Errorf("stored integrators cover %d stages, expected %d",
+			n, len(h.stages[h.hashSize]))
+	}
+	if n := len(stored.Systems); n != 0 && n != len(h.stages[h.hashSize]) {
+		return computeSpec{}, fmt.Errorf("stored systems cover %d stages, expected %d",
+			n, len(h.stages[h.hashSize]))
 	}
 
-	// Verify checkpoints
-	if len(recomputed.Checkpoints) != len(stored.Checkpoints) {
-		return false, nil
+	if err := validateEngine(stored.Engine, stored.Systems, stored.Integrators); err != nil {
+		return computeSpec{}, err
+	}
+
+	return computeSpec{
+		alg:         alg,
+		params:      deriveAdaptiveParameters(data, stored.Salt.MasterSalt),
+		cost:        cost,
+		integrators: stored.Integrators,
+		engine:      stored.Engine,
+		systems:     stored.Systems,
+	}, nil
+}
+
+// hashesMatch compares the final hash and every checkpoint in constant time.
+// All comparisons run regardless of where the first mismatch occurs. Compact
+// encodings carry no checkpoints, in which case the final hash is
+// authoritative. Only the public checkpoint count and the presence of stored
+// parameters influence control flow.
+func hashesMatch(recomputed, stored *HardenedSaltedHash) bool {
+	match := constantTimeCompare(recomputed.Hash, stored.Hash)
+
+	// Stored parameters must equal those recomputed from data and salt
+	if stored.Parameters != nil {
+		match &= constantTimeCompare(
+			recomputed.Parameters.bytes(), stored.Parameters.bytes())
 	}
 
-	for i, cp := range recomputed.Checkpoints {
-		if cp.Hash != stored.Checkpoints[i].Hash {
-			return false, nil
+	if len(stored.Checkpoints) > 0 {
+		match &= constantTimeEq(
+			int32(len(recomputed.Checkpoints)), int32(len(stored.Checkpoints)))
+
+		for i, cp := range recomputed.Checkpoints {
+			var want []byte
+			if i < len(stored.Checkpoints) {
+				want = []byte(stored.Checkpoints[i].Hash)
+			}
+			match &= constantTimeCompare([]byte(cp.Hash), want)
 		}
 	}
 
-	return true, nil
+	return match == 1
 }
 
+// The comparisons of hashesMatch, swapped out by tests that trace them.
+var (
+	constantTimeCompare = subtle.ConstantTimeCompare
+	constantTimeEq      = subtle.ConstantTimeEq
+)
+
 func (h *HardenedLorenzHasher) generateSalt() (*HierarchicalSalt, error) {
-	return GenerateSaltHierarchy(len(h.stages[h.hashSize]), int(h.hashSize))
+	var hour int64
+	if !h.untimed {
+		hour = EpochHour(h.clock())
+	}
+	return generateSaltHierarchy(hour, len(h.stages[h.hashSize]), int(h.hashSize))
 }
 
 func (h *HardenedLorenzHasher) ExposeStages() []LorenzStage {
@@ -0,0 +1,298 @@
+// =======================
+// qhash/lorenz_test.go
+// =======================
+
+package qhash
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// matchFixture returns a recomputed hash and an identical stored copy with
+// n checkpoints.
+func matchFixture(n int) (recomputed, stored *HardenedSaltedHash) {
+	mk := func() *HardenedSaltedHash {
+		h := &HardenedSaltedHash{
+			Hash:       bytes.Repeat([]byte{0xa5}, 32),
+			Parameters: deriveAdaptiveParameters([]byte("data"), []byte("salt")),
+		}
+		for i := 0; i < n; i++ {
+			h.Checkpoints = append(h.Checkpoints, TrajectoryCheckpoint{
+				Stage:     i,
+				Iteration: 1000 * (i + 1),
+				Hash:      fmt.Sprintf("checkpoint-%d", i),
+				Size:      32,
+			})
+		}
+		return h
+	}
+	return mk(), mk()
+}
+
+func TestHashesMatch(t *testing.T) {
+	recomputed, stored := matchFixture(4)
+	if !hashesMatch(recomputed, stored) {
+		t.Fatal("identical hashes do not match")
+	}
+}
+
+func TestHashesMatchFinalHash(t *testing.T) {
+	for i := 0; i < 32; i += 31 {
+		recomputed, stored := matchFixture(4)
+		stored.Hash[i] ^= 1
+		if hashesMatch(recomputed, stored) {
+			t.Errorf("hash differing in byte %d matches", i)
+		}
+	}
+
+	recomputed, stored := matchFixture(4)
+	stored.Hash = stored.Hash[:31]
+	if hashesMatch(recomputed, stored) {
+		t.Error("truncated hash matches")
+	}
+}
+
+func TestHashesMatchCheckpoint(t *testing.T) {
+	for i := 0; i < 4; i++ {
+		recomputed, stored := matchFixture(4)
+		stored.Checkpoints[i].Hash += "x"
+		if hashesMatch(recomputed, stored) {
+			t.Errorf("checkpoint %d mismatch not detected", i)
+		}
+	}
+}
+
+func TestHashesMatchCheckpointCount(t *testing.T) {
+	recomputed, stored := matchFixture(4)
+	stored.Checkpoints = stored.Checkpoints[:3]
+	if hashesMatch(recomputed, stored) {
+		t.Error("missing stored checkpoint not detected")
+	}
+
+	recomputed, stored = matchFixture(4)
+	recomputed.Checkpoints = recomputed.Checkpoints[:3]
+	if hashesMatch(recomputed, stored) {
+		t.Error("extra stored checkpoint not detected")
+	}
+}
+
+func TestHashesMatchWithoutCheckpoints(t *testing.T) {
+	// Compact encodings carry no checkpoints; the final hash decides.
+	recomputed, stored := matchFixture(4)
+	stored.Checkpoints = nil
+	if !hashesMatch(recomputed, stored) {
+		t.Error("hash without checkpoints does not match")
+	}
+	stored.Hash[0] ^= 1
+	if hashesMatch(recomputed, stored) {
+		t.Error("hash without checkpoints matches despite a different hash")
+	}
+}
+
+func TestHashesMatchParameters(t *testing.T) {
+	recomputed, stored := matchFixture(4)
+	stored.Parameters.RhoPerturbation += 0.5
+	if hashesMatch(recomputed, stored) {
+		t.Error("parameter mismatch not detected")
+	}
+
+	recomputed, stored = matchFixture(4)
+	stored.Parameters = nil
+	if !hashesMatch(recomputed, stored) {
+		t.Error("hash without stored parameters does not match")
+	}
+}
+
+// traceComparisons records the operand lengths of every comparison
+// hashesMatch makes while f runs.
+func traceComparisons(t *testing.T, f func()) []string {
+	t.Helper()
+	var trace []string
+	compare, eq := constantTimeCompare, constantTimeEq
+	t.Cleanup(func() { constantTimeCompare, constantTimeEq = compare, eq })
+	constantTimeCompare = func(x, y []byte) int {
+		trace = append(trace, fmt.Sprintf("compare %d %d", len(x), len(y)))
+		return compare(x, y)
+	}
+	constantTimeEq = func(x, y int32) int {
+		trace = append(trace, "eq")
+		return eq(x, y)
+	}
+	f()
+	constantTimeCompare, constantTimeEq = compare, eq
+	return trace
+}
+
+func TestHashesMatchRunsEveryComparison(t *testing.T) {
+	recomputed, stored := matchFixture(4)
+	want := traceComparisons(t, func() { hashesMatch(recomputed, stored) })
+	if len(want) != 7 {
+		t.Fatalf("matching hashes made %d comparisons, want 7: %v", len(want), want)
+	}
+
+	// Same-length mismatches anywhere must make exactly the same
+	// comparisons as a match.
+	mismatches := map[string]func(stored *HardenedSaltedHash){
+		"first hash byte": func(s *HardenedSaltedHash) { s.Hash[0] ^= 1 },
+		"last hash byte":  func(s *HardenedSaltedHash) { s.Hash[31] ^= 1 },
+		"parameters":      func(s *HardenedSaltedHash) { s.Parameters.RhoPerturbation += 0.5 },
+		"everything": func(s *HardenedSaltedHash) {
+			s.Hash[0] ^= 1
+			s.Parameters.RhoPerturbation += 0.5
+			for i := range s.Checkpoints {
+				s.Checkpoints[i].Hash = "x" + s.Checkpoints[i].Hash[1:]
+			}
+		},
+	}
+	for i := 0; i < 4; i++ {
+		i := i
+		mismatches[fmt.Sprintf("checkpoint %d", i)] = func(s *HardenedSaltedHash) {
+			s.Checkpoints[i].Hash = "x" + s.Checkpoints[i].Hash[1:]
+		}
+	}
+	for name, mutate := range mismatches {
+		recomputed, stored := matchFixture(4)
+		mutate(stored)
+		var ok bool
+		got := traceComparisons(t, func() { ok = hashesMatch(recomputed, stored) })
+		if ok {
+			t.Errorf("%s: mismatch not detected", name)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s: comparisons %v, want %v", name, got, want)
+		}
+	}
+
+	// A missing stored checkpoint changes only the compared lengths, never
+	// how many comparisons run.
+	recomputed, stored = matchFixture(4)
+	stored.Checkpoints = stored.Checkpoints[:1]
+	if got := traceComparisons(t, func() { hashesMatch(recomputed, stored) }); len(got) != len(want) {
+		t.Errorf("missing checkpoints: %d comparisons, want %d", len(got), len(want))
+	}
+}
+
+func TestComputeCancelledDuringLanes(t *testing.T) {
+	h, err := NewHardenedLorenzHasher(1024,
+		WithTimeCost(20), WithLanes(4), WithMemoryHardness(0), WithoutMinComputeTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err = h.HashWithHardeningContext(ctx, []byte("data"))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+	if dt := time.Since(start); dt > time.Second {
+		t.Errorf("cancelled hash returned after %v", dt)
+	}
+}
+
+func TestComputeCancelledDuringMinComputeTime(t *testing.T) {
+	h, err := NewHardenedLorenzHasher(256, WithEngine(EngineFloat64), WithMemoryHardness(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.minComputeTime = 10 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = h.HashWithHardeningContext(ctx, []byte("data"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if dt := time.Since(start); dt > 2*time.Second {
+		t.Errorf("cancelled hash returned after %v", dt)
+	}
+}
+
+// progressiveFixture returns a cheap two-lane hasher and a hash it made,
+// with one checkpoint per stage and lane.
+func progressiveFixture(t *testing.T) (*HardenedLorenzHasher, *HardenedSaltedHash) {
+	t.Helper()
+	h, err := NewHardenedLorenzHasher(256,
+		WithEngine(EngineFloat64), WithLanes(2), WithMemoryHardness(0), WithoutMinComputeTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := h.HashWithHardening([]byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := 2 * len(h.stages[h.hashSize]); len(stored.Checkpoints) != n {
+		t.Fatalf("%d checkpoints, want %d", len(stored.Checkpoints), n)
+	}
+	return h, stored
+}
+
+func TestVerifyProgressive(t *testing.T) {
+	h, stored := progressiveFixture(t)
+
+	// Lane 1's checkpoints differ from lane 0's, so comparing a
+	// lane-1 checkpoint at the wrong index would reject the hash.
+	stages := len(h.stages[h.hashSize])
+	if stored.Checkpoints[stages].Lane != 1 || stored.Checkpoints[stages].Hash == stored.Checkpoints[0].Hash {
+		t.Fatal("lane 1 checkpoints do not follow lane 0's")
+	}
+
+	// A match still waits out the minimum compute time
+	h.minComputeTime = 300 * time.Millisecond
+	start := time.Now()
+	ok, err := h.VerifyProgressive(context.Background(), []byte("data"), stored, false)
+	if err != nil || !ok {
+		t.Fatalf("valid hash rejected: %v, %v", ok, err)
+	}
+	if dt := time.Since(start); dt < h.minComputeTime {
+		t.Errorf("match returned after %v, before the minimum compute time", dt)
+	}
+
+	h.minComputeTime = 0
+	if ok, err := h.VerifyProgressive(context.Background(), []byte("other"), stored, false); err != nil || ok {
+		t.Errorf("other data: %v, %v", ok, err)
+	}
+}
+
+func TestVerifyProgressiveRejectsEarly(t *testing.T) {
+	h, stored := progressiveFixture(t)
+	stages := len(h.stages[h.hashSize])
+
+	// A mismatch must return before the minimum compute time; the
+	// deadline turns a late rejection into an error.
+	h.minComputeTime = time.Hour
+	for _, i := range []int{0, stages - 1, stages, 2*stages - 1} {
+		tampered := *stored
+		tampered.Checkpoints = append([]TrajectoryCheckpoint(nil), stored.Checkpoints...)
+		tampered.Checkpoints[i].Hash = stored.Checkpoints[(i+1)%len(stored.Checkpoints)].Hash
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ok, err := h.VerifyProgressive(ctx, []byte("data"), &tampered, false)
+		cancel()
+		if err != nil || ok {
+			t.Errorf("lane %d stage %d checkpoint tampered: %v, %v",
+				stored.Checkpoints[i].Lane, stored.Checkpoints[i].Stage, ok, err)
+		}
+	}
+}
+
+func TestVerifyProgressiveWithoutCheckpoints(t *testing.T) {
+	h, stored := progressiveFixture(t)
+	stored.Checkpoints = nil
+	if ok, err := h.VerifyProgressive(context.Background(), []byte("data"), stored, false); err != nil || !ok {
+		t.Errorf("hash without checkpoints rejected: %v, %v", ok, err)
+	}
+
+	stored.Hash = append([]byte(nil), stored.Hash...)
+	stored.Hash[0] ^= 1
+	if ok, err := h.VerifyProgressive(context.Background(), []byte("data"), stored, false); err != nil || ok {
+		t.Errorf("wrong hash without checkpoints: %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,28 @@
+// =======================
+// qhash/mac.go
+// =======================
+
+package qhash
+
+import (
+	"crypto/hmac"
+	"hash"
+)
+
+// NewMAC returns an HMAC keyed by key over the deterministic size-bit QHASH
+// digest. Like every digest it runs without the memory-hard phase and the
+// minimum compute time, so a tag costs two plain Lorenz computations (inner
+// and outer hash). Compare tags with Equal.
+func NewMAC(key []byte, size int) (hash.Hash, error) {
+	h, err := NewHardenedLorenzHasher(size)
+	if err != nil {
+		return nil, err
+	}
+	return hmac.New(h.NewDigest, key), nil
+}
+
+// Equal reports whether two MAC tags are equal without leaking timing
+// information about where they differ.
+func Equal(a, b []byte) bool {
+	return hmac.Equal(a, b)
+}
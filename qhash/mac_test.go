@@ -0,0 +1,51 @@
+// =======================
+// qhash/mac_test.go
+// =======================
+
+package qhash
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func macTag(t *testing.T, key, msg string) []byte {
+	t.Helper()
+	m, err := NewMAC([]byte(key), 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Write([]byte(msg))
+	return m.Sum(nil)
+}
+
+func TestMACKnownAnswer(t *testing.T) {
+	const want = "0caa24d32b1b4f45f6e146860160d4dcdcdeb189fdf17a2a09d714002650e5c6"
+	if got := hex.EncodeToString(macTag(t, "secret key", "reference")); got != want {
+		t.Errorf("tag %s, want %s", got, want)
+	}
+}
+
+func TestMACEqual(t *testing.T) {
+	tag := macTag(t, "secret key", "message")
+	if !Equal(tag, macTag(t, "secret key", "message")) {
+		t.Fatal("recomputed tag rejected")
+	}
+
+	for _, i := range []int{0, len(tag) / 2, len(tag) - 1} {
+		tampered := append([]byte(nil), tag...)
+		tampered[i] ^= 0x80
+		if Equal(tampered, tag) {
+			t.Errorf("tag tampered at byte %d accepted", i)
+		}
+	}
+	if Equal(tag[:len(tag)-1], tag) {
+		t.Error("truncated tag accepted")
+	}
+	if Equal(macTag(t, "other key", "message"), tag) {
+		t.Error("tag under another key accepted")
+	}
+	if Equal(macTag(t, "secret key", "massage"), tag) {
+		t.Error("tag of another message accepted")
+	}
+}
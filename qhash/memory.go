@@ -0,0 +1,116 @@
+// =======================
+// qhash/memory.go
+// =======================
+
+package qhash
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// memoryBlockSize is the unit the memory-hard buffer is filled in.
+const memoryBlockSize = sha512.Size
+
+// memoryHardMix fills memoryKiB of memory split across lanes, then revisits
+// each lane in a data-dependent order before compressing the lane results to
+// outSize bytes. Lanes are independent and computed concurrently. ctx is
+// checked before every block.
+func memoryHardMix(ctx context.Context, seed []byte, memoryKiB, lanes, outSize int) ([]byte, error) {
+	if len(seed) == 0 {
+		return nil, fmt.Errorf("empty seed")
+	}
+	if lanes < 1 || memoryKiB < lanes {
+		return nil, fmt.Errorf("invalid memory layout: %d KiB across %d lanes", memoryKiB, lanes)
+	}
+
+	blocks := memoryKiB * 1024 / memoryBlockSize / lanes
+	results := make([][]byte, lanes)
+	errs := make([]error, lanes)
+
+	var wg sync.WaitGroup
+	for l := 0; l < lanes; l++ {
+		wg.Add(1)
+		go func(l int) {
+			defer wg.Done()
+			results[l], errs[l] = fillLane(ctx, seed, l, blocks)
+		}(l)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	combined := make([]byte, 0, lanes*memoryBlockSize)
+	for _, r := range results {
+		combined = append(combined, r...)
+	}
+
+	out := make([]byte, 0, outSize+memoryBlockSize)
+	for ctr := uint32(0); len(out) < outSize; ctr++ {
+		h := sha512.Sum512(binary.BigEndian.AppendUint32(combined, ctr))
+		out = append(out, h[:]...)
+	}
+	return out[:outSize], nil
+}
+
+// memoryBufferKiB returns the memory actually allocated by memoryHardMix
+// for the given layout, after rounding down to whole blocks per lane.
+func memoryBufferKiB(memoryKiB, lanes int) int {
+	if memoryKiB <= 0 || lanes < 1 {
+		return 0
+	}
+	blocks := memoryKiB * 1024 / memoryBlockSize / lanes
+	return blocks * lanes * memoryBlockSize / 1024
+}
+
+// fillLane performs the fill and revisit passes over one lane and returns
+// the final running block.
+func fillLane(ctx context.Context, seed []byte, lane, blocks int) ([]byte, error) {
+	mem := make([]byte, blocks*memoryBlockSize)
+	h := sha512.New()
+	var idx [8]byte
+
+	// Fill: each block depends on the previous one
+	prev := seed
+	for i := 0; i < blocks; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		h.Reset()
+		h.Write(prev)
+		binary.BigEndian.PutUint32(idx[:4], uint32(lane))
+		binary.BigEndian.PutUint32(idx[4:], uint32(i))
+		h.Write(idx[:])
+		blk := mem[i*memoryBlockSize : (i+1)*memoryBlockSize]
+		h.Sum(blk[:0])
+		prev = blk
+	}
+
+	// Revisit: the next block read is chosen by the running state
+	x := make([]byte, memoryBlockSize)
+	copy(x, prev)
+	for i := 0; i < blocks; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		j := int(binary.LittleEndian.Uint64(x[:8]) % uint64(blocks))
+		blk := mem[j*memoryBlockSize : (j+1)*memoryBlockSize]
+		for k := range x {
+			x[k] ^= blk[k]
+		}
+		h.Reset()
+		h.Write(x)
+		binary.BigEndian.PutUint64(idx[:], uint64(i))
+		h.Write(idx[:])
+		h.Sum(x[:0])
+		copy(blk, x)
+	}
+
+	return x, nil
+}
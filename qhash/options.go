@@ -0,0 +1,127 @@
+// =======================
+// qhash/options.go
+// =======================
+
+package qhash
+
+// Option configures a HardenedLorenzHasher.
+type Option func(*HardenedLorenzHasher)
+
+// WithKey selects keyed deterministic mode: Hash and the streaming digest
+// derive their salt hierarchy from key instead of the unkeyed default.
+func WithKey(key []byte) Option {
+	return func(h *HardenedLorenzHasher) {
+		h.key = append([]byte(nil), key...)
+	}
+}
+
+// WithTimeCost multiplies every stage's iteration count by t.
+func WithTimeCost(t int) Option {
+	return func(h *HardenedLorenzHasher) {
+		h.timeCost = t
+	}
+}
+
+// WithMemoryHardness sets the size of the memory-hard buffer in KiB.
+// Zero disables the memory-hard phase.
+func WithMemoryHardness(kib int) Option {
+	return func(h *HardenedLorenzHasher) {
+		h.memoryHardness = kib
+	}
+}
+
+// WithParallelism splits the memory-hard buffer into p independent lanes
+// that are filled concurrently.
+func WithParallelism(p int) Option {
+	return func(h *HardenedLorenzHasher) {
+		h.parallelism = p
+	}
+}
+
+// WithLanes runs n independent chains of stages concurrently and merges
+// them before finalization. Each lane runs 1/n of every stage's iterations,
+// but at least MinIterations plus the warm-up, so n cores shorten a hash
+// until lanes reach that floor. The lane count is recorded in hardened
+// hashes.
+func WithLanes(n int) Option {
+	return func(h *HardenedLorenzHasher) {
+		h.lanes = n
+	}
+}
+
+// WithClock replaces time.Now as the source of the epoch hour bound into
+// freshly generated salts.
+func WithClock(clock Clock) Option {
+	return func(h *HardenedLorenzHasher) {
+		if clock != nil {
+			h.clock = clock
+		}
+	}
+}
+
+// WithoutTimeBinding omits time-binding from generated salts. The timestamp
+// salt is then a constant and EpochHour is recorded as 0.
+func WithoutTimeBinding() Option {
+	return func(h *HardenedLorenzHasher) {
+		h.untimed = true
+	}
+}
+
+// WithoutMinComputeTime removes the MinComputeTime floor that every
+// computation otherwise waits for. It suits content hashing, where the floor
+// only adds latency; keep it for passwords.
+func WithoutMinComputeTime() Option {
+	return func(h *HardenedLorenzHasher) {
+		h.minComputeTime = 0
+	}
+}
+
+// WithIntegrator selects the integrator used by every stage.
+func WithIntegrator(name string) Option {
+	return func(h *HardenedLorenzHasher) {
+		for i := range h.stages[h.hashSize] {
+			h.stages[h.hashSize][i].Integrator = name
+		}
+	}
+}
+
+// WithStageIntegrator selects the integrator used by a single stage.
+// Out-of-range stage indexes are ignored.
+func WithStageIntegrator(stage int, name string) Option {
+	return func(h *HardenedLorenzHasher) {
+		if stage >= 0 && stage < len(h.stages[h.hashSize]) {
+			h.stages[h.hashSize][stage].Integrator = name
+		}
+	}
+}
+
+// WithSystem selects the chaotic system integrated by every stage. Stages
+// other than Lorenz use the system's DefaultParams.
+func WithSystem(name string) Option {
+	return func(h *HardenedLorenzHasher) {
+		for i := range h.stages[h.hashSize] {
+			h.stages[h.hashSize][i].System = name
+			h.stages[h.hashSize][i].Params = nil
+		}
+	}
+}
+
+// WithStageSystem selects the chaotic system integrated by a single stage.
+// Out-of-range stage indexes are ignored.
+func WithStageSystem(stage int, name string) Option {
+	return func(h *HardenedLorenzHasher) {
+		if stage >= 0 && stage < len(h.stages[h.hashSize]) {
+			h.stages[h.hashSize][stage].System = name
+			h.stages[h.hashSize][stage].Params = nil
+		}
+	}
+}
+
+// WithEngine selects the trajectory engine: EngineBigFloat, EngineFixed or
+// EngineFloat64. The fixed-point and float64 engines only support the Lorenz
+// and 4D hyperchaotic Lorenz systems with the Euler integrator.
+func WithEngine(name string) Option {
+	return func(h *HardenedLorenzHasher) {
+		h.engine = name
+	}
+}
@@ -0,0 +1,117 @@
+// =======================
+// qhash/password.go
+// =======================
+
+package qhash
+
+import "fmt"
+
+// PasswordParams are the work factors used for password hashing. All of them
+// are encoded into the stored string so costs can be raised over time.
+type PasswordParams struct {
+	HashSize    int // output size in bits
+	TimeCost    int // stage iteration multiplier
+	MemoryCost  int // memory-hard buffer size in KiB
+	Parallelism int // independent memory lanes
+}
+
+// DefaultPasswordParams is the recommended starting point for credentials.
+var DefaultPasswordParams = PasswordParams{
+	HashSize:    256,
+	TimeCost:    2,
+	MemoryCost:  16 * 1024,
+	Parallelism: 1,
+}
+
+func (p PasswordParams) hasher(opts ...Option) (*HardenedLorenzHasher, error) {
+	return NewHardenedLorenzHasher(p.HashSize, append([]Option{
+		WithTimeCost(p.TimeCost),
+		WithMemoryHardness(p.MemoryCost),
+		WithParallelism(p.Parallelism),
+	}, opts...)...)
+}
+
+// HashPassword hashes password with a fresh salt and returns the encoded
+// $qhash$ string to store.
+func HashPassword(password []byte, params PasswordParams) (string, error) {
+	if params.MemoryCost <= 0 {
+		return "", fmt.Errorf("password hashing requires a memory cost")
+	}
+
+	h, err := params.hasher()
+	if err != nil {
+		return "", fmt.Errorf("invalid password parameters: %w", err)
+	}
+
+	result, err := h.HashWithHardening(password)
+	if err != nil {
+		return "", err
+	}
+	return result.EncodePHC()
+}
+
+// VerifyPassword checks password against an encoded $qhash$ string using the
+// parameters recorded in it. Memory costs up to MaxMemoryHardness are
+// accepted; use VerifyPasswordLimit when encoded comes from an untrusted
+// source.
+func VerifyPassword(password []byte, encoded string) (bool, error) {
+	return VerifyPasswordLimit(password, encoded, MaxMemoryHardness)
+}
+
+// VerifyPasswordLimit is VerifyPassword but rejects encoded strings whose
+// memory cost exceeds maxMemory KiB before allocating the buffer.
+func VerifyPasswordLimit(password []byte, encoded string, maxMemory int) (bool, error) {
+	stored, err := ParsePHC(encoded)
+	if err != nil {
+		return false, err
+	}
+	if stored.MemoryCost > maxMemory {
+		return false, fmt.Errorf("memory cost %d KiB exceeds limit of %d KiB",
+			stored.MemoryCost, maxMemory)
+	}
+
+	h, err := NewHardenedLorenzHasher(stored.HashSize)
+	if err != nil {
+		return false, err
+	}
+	return h.VerifyHardenedHash(password, stored)
+}
+
+// NeedsRehash reports whether encoded differs from what HashPassword would
+// produce with params today: another algorithm version, size, cost, lane
+// count, engine, integrators, systems or stage config. Call it after a
+// successful VerifyPassword and store a fresh hash if it returns true.
+func NeedsRehash(encoded string, params PasswordParams) (bool, error) {
+	stored, err := ParsePHC(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	h, err := params.hasher()
+	if err != nil {
+		return false, fmt.Errorf("invalid password parameters: %w", err)
+	}
+	return h.outdated(stored), nil
+}
+
+// UpgradePassword verifies password against encoded and, if it matches but
+// NeedsRehash reports encoded as stale, returns a fresh encoding made with
+// params to store in its place. The returned string is empty when the
+// password does not match or encoded is current.
+func UpgradePassword(password []byte, encoded string, params PasswordParams) (bool, string, error) {
+	ok, err := VerifyPassword(password, encoded)
+	if err != nil || !ok {
+		return ok, "", err
+	}
+
+	stale, err := NeedsRehash(encoded, params)
+	if err != nil || !stale {
+		return true, "", err
+	}
+
+	upgraded, err := HashPassword(password, params)
+	if err != nil {
+		return true, "", fmt.Errorf("rehash failed: %w", err)
+	}
+	return true, upgraded, nil
+}
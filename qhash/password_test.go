@@ -0,0 +1,56 @@
+// =======================
+// qhash/password_test.go
+// =======================
+
+package qhash
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNeedsRehash(t *testing.T) {
+	params := PasswordParams{HashSize: 256, TimeCost: 1, MemoryCost: 64, Parallelism: 1}
+	encoded, err := HashPassword([]byte("password"), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := NeedsRehash(encoded, params); err != nil || stale {
+		t.Fatalf("fresh hash reported stale (%v)", err)
+	}
+
+	fields := strings.Split(encoded, "$")
+	with := func(extra string) string {
+		f := append([]string(nil), fields...)
+		f[3] += "," + extra
+		return strings.Join(f, "$")
+	}
+	stale := map[string]string{
+		"version":     strings.Replace(encoded, "v="+AlgorithmVersion, "v="+HyperchaosVersion, 1),
+		"time cost":   strings.Replace(encoded, "t=1", "t=2", 1),
+		"memory cost": strings.Replace(encoded, "m=64", "m=128", 1),
+		"parallelism": strings.Replace(encoded, "p=1", "p=2", 1),
+		"lanes":       with("l=2"),
+		"engine":      with("g=" + EngineFloat64),
+		"integrators": with("i=rk4-rk4"),
+		"systems":     with("c=hyperlorenz-hyperlorenz"),
+		"config":      with("f=0123456789abcdef0123456789abcdef"),
+	}
+	for name, s := range stale {
+		if s == encoded {
+			t.Fatalf("%s: %s unchanged", name, s)
+		}
+		got, err := NeedsRehash(s, params)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if !got {
+			t.Errorf("%s: %s reported current", name, s)
+		}
+	}
+
+	other := params
+	other.HashSize = 512
+	if got, err := NeedsRehash(encoded, other); err != nil || !got {
+		t.Errorf("size change not reported (%v)", err)
+	}
+}
@@ -1,9 +1,11 @@
 package qhash
 
 import (
+	"context"
 	"crypto/sha256"
 	"crypto/sha512"
 	"fmt"
+	"math/big"
 )
 
 // lorenzMix: XOR with SHA-derived bytes
@@ -24,8 +26,56 @@ func lorenzMix(data, salt []byte) ([]byte, error) {
 	return out, nil
 }
 
-// hyperchaosMix: 4D chaotic mixing
-func hyperchaosMix(data, salt []byte) ([]byte, error) {
+// hyperchaosMix XORs data with a keystream from the 4D hyperchaotic Lorenz
+// system seeded by data||salt and integrated by engine. The whole stream is
+// folded cyclically onto data. With EngineBigFloat it equals
+// bigHyperchaosMix.
+func hyperchaosMix(ctx context.Context, engine Engine, data, salt []byte) ([]byte, error) {
+	if len(data) == 0 || len(salt) == 0 {
+		return nil, fmt.Errorf("empty input")
+	}
+	se, ok := engine.(streamer)
+	if !ok {
+		return nil, fmt.Errorf("%s engine cannot run the hyperchaos round", engine.Name())
+	}
+
+	sys := HyperLorenz{}
+	state, err := seedState(data, salt, sys.Dim(), sys.Radius())
+	if err != nil {
+		return nil, err
+	}
+	stream, err := se.stream(ctx, Trajectory{
+		System:     SystemHyperLorenz,
+		State:      state,
+		Params:     sys.DefaultParams(),
+		Dt:         hyperchaosDt,
+		Iterations: MinIterations,
+		Discard:    hyperchaosDiscard,
+	}, []int{0})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, len(data))
+	copy(out, data)
+	for i, b := range stream {
+		out[i%len(out)] ^= b
+	}
+	return out, nil
+}
+
+// bigHyperchaosMix is the hyperchaos round of algorithm version 2.2, which
+// always integrates with big.Float whatever the engine.
+func bigHyperchaosMix(data, salt []byte) ([]byte, error) {
+	return hyperchaosMix(context.Background(), BigFloatEngine{}, data, salt)
+}
+
+const hyperchaosDiscard = 500
+
+var hyperchaosDt = big.NewFloat(0.01).SetPrec(128)
+
+// legacyHyperchaosMix is the XOR-only mix used before algorithm version 2.2.
+func legacyHyperchaosMix(data, salt []byte) ([]byte, error) {
 	if len(data) == 0 || len(salt) == 0 {
 		return nil, fmt.Errorf("empty input")
 	}
@@ -64,7 +114,12 @@ func latticeMix(data, salt []byte) ([]byte, error) {
 }
 
 // quantumFinalize: Multi-round mixing for quantum resistance
-func quantumFinalize(data []byte, salt *HierarchicalSalt, hashSize HashSize) ([]byte, error) {
+// version selects the hyperchaos round from the algorithm registry; from
+// version 2.3 it runs on engine.
+func quantumFinalize(
+	ctx context.Context, engine Engine,
+	data []byte, salt *HierarchicalSalt, hashSize HashSize, version string,
+) ([]byte, error) {
 	if len(data) == 0 || salt == nil {
 		return nil, fmt.Errorf("invalid input")
 	}
@@ -98,7 +153,11 @@ func quantumFinalize(data []byte, salt *HierarchicalSalt, hashSize HashSize) ([]
 	}
 
 	// Round 3: Hyperchaos mixing
-	r3, err := hyperchaosMix(r2, salt.TimestampSalt)
+	alg, ok := algorithms[version]
+	if !ok {
+		return nil, fmt.Errorf("unsupported algorithm version: %s", version)
+	}
+	r3, err := alg.mix(ctx, engine, r2, salt.TimestampSalt)
 	if err != nil {
 		return nil, fmt.Errorf("hyperchaos mix failed: %w", err)
 	}
@@ -11,23 +11,62 @@ import (
 	"time"
 )
 
+// keyedSaltDomain separates keyed master salts from other SHA256 chains.
+const keyedSaltDomain = "QHASH-KEYED-MASTER"
+
 // GenerateSaltHierarchy builds Master, Stage, Timestamp, Meta salts.
 func GenerateSaltHierarchy(numStages, hashSize int) (*HierarchicalSalt, error) {
-	if numStages <= 0 || numStages > 10 {
-		return nil, fmt.Errorf("invalid number of stages")
-	}
+	// Time-based salt (changes hourly to prevent rainbow tables)
+	return generateSaltHierarchy(EpochHour(time.Now()), numStages, hashSize)
+}
 
-	// Scale salt sizes based on hash size
-	masterSize := 32 + (hashSize-256)/256*16 // 32-80 bytes
-	if masterSize > 80 {
-		masterSize = 80
-	}
+// EpochHour returns the hour index bound into the timestamp salt for t.
+func EpochHour(t time.Time) int64 {
+	return t.Unix() / 3600
+}
 
-	master := make([]byte, masterSize)
+// generateSaltHierarchy builds a hierarchy around a random master salt bound
+// to hour. Hour 0 means the hierarchy is not bound to any time.
+func generateSaltHierarchy(hour int64, numStages, hashSize int) (*HierarchicalSalt, error) {
+	master := make([]byte, masterSaltSize(hashSize))
 	if _, err := rand.Read(master); err != nil {
 		return nil, fmt.Errorf("master salt generation failed: %w", err)
 	}
 
+	return buildSaltHierarchy(master, hour, numStages, hashSize)
+}
+
+// DeriveSaltHierarchy deterministically builds the full salt hierarchy from
+// key. A nil or empty key yields the unkeyed hierarchy. The result is not
+// bound to the wall clock, so the same key always produces the same salts.
+func DeriveSaltHierarchy(key []byte, numStages, hashSize int) (*HierarchicalSalt, error) {
+	seed := make([]byte, 0, len(keyedSaltDomain)+len(key))
+	seed = append(seed, keyedSaltDomain...)
+	seed = append(seed, key...)
+
+	master := deriveSaltLR(seed, masterSaltSize(hashSize))
+	if master == nil {
+		return nil, fmt.Errorf("master salt derivation failed")
+	}
+
+	return buildSaltHierarchy(master, 0, numStages, hashSize)
+}
+
+// masterSaltSize scales the master salt with the hash size (32-80 bytes).
+func masterSaltSize(hashSize int) int {
+	size := 32 + (hashSize-256)/256*16
+	if size > 80 {
+		size = 80
+	}
+	return size
+}
+
+// buildSaltHierarchy derives the stage, timestamp and meta salts from master.
+func buildSaltHierarchy(master []byte, hour int64, numStages, hashSize int) (*HierarchicalSalt, error) {
+	if numStages <= 0 || numStages > MaxStages {
+		return nil, fmt.Errorf("invalid number of stages")
+	}
+
 	stageSaltSize := 16 + (hashSize-256)/256*8 // 16-48 bytes
 	if stageSaltSize > 48 {
 		stageSaltSize = 48
@@ -35,7 +74,9 @@ func GenerateSaltHierarchy(numStages, hashSize int) (*HierarchicalSalt, error) {
 
 	stageSalts := make([][]byte, numStages)
 	for i := 0; i < numStages; i++ {
-		seed := append(master, byte(i))
+		seed := make([]byte, 0, len(master)+1)
+		seed = append(seed, master...)
+		seed = append(seed, byte(i))
 		salt := deriveSaltLR(seed, stageSaltSize)
 		if salt == nil {
 			return nil, fmt.Errorf("stage %d salt generation failed", i)
@@ -43,10 +84,8 @@ func GenerateSaltHierarchy(numStages, hashSize int) (*HierarchicalSalt, error) {
 		stageSalts[i] = salt
 	}
 
-	// Time-based salt (changes hourly to prevent rainbow tables)
-	hr := time.Now().Unix() / 3600
 	tb := make([]byte, 8)
-	binary.BigEndian.PutUint64(tb, uint64(hr))
+	binary.BigEndian.PutUint64(tb, uint64(hour))
 
 	timestampSize := 12 + (hashSize-256)/256*4 // 12-28 bytes
 	if timestampSize > 28 {
@@ -82,5 +121,6 @@ func GenerateSaltHierarchy(numStages, hashSize int) (*HierarchicalSalt, error) {
 		TimestampSalt: ts,
 		MetaSalt:      meta,
 		HashSize:      hashSize,
+		EpochHour:     hour,
 	}, nil
 }
@@ -0,0 +1,347 @@
+// =======================
+// qhash/selftest.go
+// =======================
+
+package qhash
+
+import (
+	"context"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+)
+
+//go:embed vectors.json
+var vectorsJSON []byte
+
+// Known-answer vector kinds
+const (
+	VectorTrajectory = "trajectory" // one engine trajectory (TrajectoryToHashBig and friends)
+	VectorSalt       = "salt"       // deriveSaltLR
+	VectorFinalize   = "finalize"   // quantumFinalize
+	VectorHash       = "hash"       // the full hardened computation
+	VectorXOF        = "xof"        // the first bytes of NewXOF
+	VectorMAC        = "mac"        // NewMAC tag
+	VectorStream     = "stream"     // the first bytes of NewStream
+)
+
+// VectorSet is a versioned list of known-answer vectors. Version changes
+// whenever an expected value changes, which must only happen together with
+// an algorithm version bump.
+type VectorSet struct {
+	Version          int           `json:"version"`
+	AlgorithmVersion string        `json:"algorithm_version"`
+	Vectors          []KnownAnswer `json:"vectors"`
+}
+
+// KnownAnswer is one known-answer vector. Binary fields are hex encoded;
+// which fields apply depends on Kind.
+type KnownAnswer struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+
+	// salt, finalize, hash, xof, mac and stream
+	Input string `json:"input,omitempty"`
+
+	// trajectory, finalize, hash and xof
+	Engine string `json:"engine,omitempty"`
+
+	// trajectory
+	System     string   `json:"system,omitempty"`
+	Integrator string   `json:"integrator,omitempty"`
+	State      []string `json:"state,omitempty"` // decimal
+	Params     []string `json:"params,omitempty"`
+	Dt         string   `json:"dt,omitempty"`
+	Iterations int      `json:"iterations,omitempty"`
+	Discard    int      `json:"discard,omitempty"`
+
+	// salt, finalize, hash, xof, mac and stream
+	Size int `json:"size,omitempty"` // bits, or bytes for trajectory, salt, xof and stream
+
+	// finalize and hash: the salt hierarchy is rebuilt from MasterSalt and
+	// EpochHour, or derived from Key when MasterSalt is empty. Key also
+	// keys xof streams and mac tags.
+	MasterSalt string `json:"master_salt,omitempty"`
+	EpochHour  int64  `json:"epoch_hour,omitempty"`
+	Key        string `json:"key,omitempty"`
+	Version    string `json:"version,omitempty"` // "": AlgorithmVersion
+
+	// hash
+	TimeCost    int `json:"time_cost,omitempty"`
+	MemoryCost  int `json:"memory_cost_kb,omitempty"`
+	Parallelism int `json:"parallelism,omitempty"`
+	Lanes       int `json:"lanes,omitempty"`
+
+	Expected    string   `json:"expected"`
+	Checkpoints []string `json:"checkpoints,omitempty"` // base64, hash only
+}
+
+// KnownAnswers returns the vectors built into the package.
+func KnownAnswers() (*VectorSet, error) {
+	var set VectorSet
+	if err := json.Unmarshal(vectorsJSON, &set); err != nil {
+		return nil, fmt.Errorf("invalid built-in vectors: %w", err)
+	}
+	return &set, nil
+}
+
+// SelfTest checks every built-in known-answer vector and returns an error
+// listing those that fail. It takes a few seconds.
+func SelfTest() error {
+	set, err := KnownAnswers()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, v := range set.Vectors {
+		if err := v.Check(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Check recomputes v and compares the result with its expected values.
+func (v KnownAnswer) Check() error {
+	got, err := v.Compute()
+	if err != nil {
+		return fmt.Errorf("%s: %w", v.Name, err)
+	}
+	if got.Expected != v.Expected {
+		return fmt.Errorf("%s: got %s, expected %s", v.Name, got.Expected, v.Expected)
+	}
+	if strings.Join(got.Checkpoints, ",") != strings.Join(v.Checkpoints, ",") {
+		return fmt.Errorf("%s: checkpoints differ", v.Name)
+	}
+	return nil
+}
+
+// Compute returns v with Expected and Checkpoints replaced by the values
+// this implementation produces, for regenerating the vector file.
+func (v KnownAnswer) Compute() (KnownAnswer, error) {
+	var out []byte
+	var err error
+	v.Checkpoints = nil
+
+	switch v.Kind {
+	case VectorTrajectory:
+		out, err = v.trajectory()
+	case VectorSalt:
+		out, err = v.salt()
+	case VectorFinalize:
+		out, err = v.finalize()
+	case VectorXOF:
+		out, err = v.xof()
+	case VectorMAC:
+		out, err = v.mac()
+	case VectorStream:
+		out, err = v.stream()
+	case VectorHash:
+		var result *HardenedSaltedHash
+		if result, err = v.hash(); err == nil {
+			out = result.Hash
+			for _, cp := range result.Checkpoints {
+				v.Checkpoints = append(v.Checkpoints, cp.Hash)
+			}
+		}
+	default:
+		err = fmt.Errorf("unknown vector kind: %s", v.Kind)
+	}
+	if err != nil {
+		return v, err
+	}
+
+	v.Expected = hex.EncodeToString(out)
+	return v, nil
+}
+
+func (v KnownAnswer) trajectory() ([]byte, error) {
+	engine, err := LookupEngine(v.Engine)
+	if err != nil {
+		return nil, err
+	}
+	state, err := parseDecimals(v.State)
+	if err != nil {
+		return nil, fmt.Errorf("state: %w", err)
+	}
+	params, err := parseDecimals(v.Params)
+	if err != nil {
+		return nil, fmt.Errorf("params: %w", err)
+	}
+	dt, err := parseDecimals([]string{v.Dt})
+	if err != nil {
+		return nil, fmt.Errorf("dt: %w", err)
+	}
+	return engine.Run(context.Background(), Trajectory{
+		System:     v.System,
+		State:      state,
+		Params:     params,
+		Dt:         dt[0],
+		Integrator: v.Integrator,
+		Iterations: v.Iterations,
+		Discard:    v.Discard,
+		OutSize:    v.Size,
+	})
+}
+
+func (v KnownAnswer) salt() ([]byte, error) {
+	seed, err := hex.DecodeString(v.Input)
+	if err != nil {
+		return nil, fmt.Errorf("input: %w", err)
+	}
+	out := deriveSaltLR(seed, v.Size)
+	if out == nil {
+		return nil, fmt.Errorf("invalid salt size: %d", v.Size)
+	}
+	return out, nil
+}
+
+func (v KnownAnswer) finalize() ([]byte, error) {
+	data, err := hex.DecodeString(v.Input)
+	if err != nil {
+		return nil, fmt.Errorf("input: %w", err)
+	}
+	salt, err := v.saltHierarchy()
+	if err != nil {
+		return nil, err
+	}
+	engine, err := LookupEngine(v.Engine)
+	if err != nil {
+		return nil, err
+	}
+	return quantumFinalize(context.Background(), engine,
+		data, salt, HashSize(v.Size), v.version())
+}
+
+func (v KnownAnswer) hash() (*HardenedSaltedHash, error) {
+	data, err := hex.DecodeString(v.Input)
+	if err != nil {
+		return nil, fmt.Errorf("input: %w", err)
+	}
+	salt, err := v.saltHierarchy()
+	if err != nil {
+		return nil, err
+	}
+
+	// Vectors pin output, not timing
+	h, err := NewHardenedLorenzHasher(v.Size, WithoutMinComputeTime())
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := h.verifySpec(data, &HardenedSaltedHash{
+		Salt:        salt,
+		Version:     v.version(),
+		HashSize:    v.Size,
+		TimeCost:    v.TimeCost,
+		MemoryCost:  v.MemoryCost,
+		Parallelism: v.Parallelism,
+		Lanes:       v.Lanes,
+		Engine:      v.Engine,
+		Integrators: v.perStage(h, v.Integrator, DefaultIntegrator),
+		Systems:     v.perStage(h, v.System, DefaultSystem),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return h.compute(context.Background(), data, salt, spec)
+}
+
+func (v KnownAnswer) xof() ([]byte, error) {
+	data, err := hex.DecodeString(v.Input)
+	if err != nil {
+		return nil, fmt.Errorf("input: %w", err)
+	}
+	r, err := NewXOF(data, WithKey([]byte(v.Key)), WithEngine(v.Engine))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, v.Size)
+	if _, err := io.ReadFull(r, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (v KnownAnswer) mac() ([]byte, error) {
+	data, err := hex.DecodeString(v.Input)
+	if err != nil {
+		return nil, fmt.Errorf("input: %w", err)
+	}
+	m, err := NewMAC([]byte(v.Key), v.Size)
+	if err != nil {
+		return nil, err
+	}
+	m.Write(data)
+	return m.Sum(nil), nil
+}
+
+func (v KnownAnswer) stream() ([]byte, error) {
+	seed, err := hex.DecodeString(v.Input)
+	if err != nil {
+		return nil, fmt.Errorf("input: %w", err)
+	}
+	s, err := NewStream(seed)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, v.Size)
+	if _, err := io.ReadFull(s, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// saltHierarchy rebuilds the salt hierarchy a finalize or hash vector
+// names.
+func (v KnownAnswer) saltHierarchy() (*HierarchicalSalt, error) {
+	stages := len(defaultStages(HashSize(v.Size)))
+	if stages == 0 {
+		return nil, fmt.Errorf("unsupported hash size: %d", v.Size)
+	}
+	if v.MasterSalt == "" {
+		return DeriveSaltHierarchy([]byte(v.Key), stages, v.Size)
+	}
+	master, err := hex.DecodeString(v.MasterSalt)
+	if err != nil {
+		return nil, fmt.Errorf("master salt: %w", err)
+	}
+	return buildSaltHierarchy(master, v.EpochHour, stages, v.Size)
+}
+
+func (v KnownAnswer) version() string {
+	if v.Version == "" {
+		return AlgorithmVersion
+	}
+	return v.Version
+}
+
+// perStage repeats name for every stage of h, or returns nil for def.
+func (v KnownAnswer) perStage(h *HardenedLorenzHasher, name, def string) []string {
+	if name == "" || name == def {
+		return nil
+	}
+	names := make([]string, len(h.stages[h.hashSize]))
+	for i := range names {
+		names[i] = name
+	}
+	return names
+}
+
+// parseDecimals parses decimal strings into 128-bit big.Floats.
+func parseDecimals(values []string) ([]*big.Float, error) {
+	out := make([]*big.Float, len(values))
+	for i, s := range values {
+		f, ok := new(big.Float).SetPrec(128).SetString(s)
+		if !ok {
+			return nil, fmt.Errorf("invalid decimal %q", s)
+		}
+		out[i] = f
+	}
+	return out, nil
+}
@@ -0,0 +1,26 @@
+// =======================
+// qhash/selftest_test.go
+// =======================
+
+package qhash
+
+import "testing"
+
+func TestKnownAnswers(t *testing.T) {
+	set, err := KnownAnswers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.AlgorithmVersion != AlgorithmVersion {
+		t.Fatalf("vectors are for algorithm %s, package implements %s",
+			set.AlgorithmVersion, AlgorithmVersion)
+	}
+	for _, v := range set.Vectors {
+		v := v
+		t.Run(v.Name, func(t *testing.T) {
+			if err := v.Check(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,322 @@
+// =======================
+// qhash/stats/nist.go
+// =======================
+
+package stats
+
+import (
+	"fmt"
+	"math"
+)
+
+// Test names as reported in Result.Name
+const (
+	NameFrequency          = "Frequency"
+	NameBlockFrequency     = "BlockFrequency"
+	NameRuns               = "Runs"
+	NameLongestRun         = "LongestRun"
+	NameSerial             = "Serial"
+	NameApproximateEntropy = "ApproximateEntropy"
+	NameCumulativeSums     = "CumulativeSums"
+	NameChiSquareBytes     = "ChiSquareBytes"
+)
+
+// minBits is the shortest sequence SP 800-22 recommends for most tests.
+const minBits = 100
+
+func checkLength(name string, n, need int) error {
+	if n < need {
+		return fmt.Errorf("%s: sequence too short: %d bits, need %d", name, n, need)
+	}
+	return nil
+}
+
+// Frequency is the monobit test (SP 800-22 2.1): the proportion of ones
+// should be close to 1/2.
+func Frequency(data []byte) (Result, error) {
+	return frequency(unpack(data))
+}
+
+func frequency(e []uint8) (Result, error) {
+	n := len(e)
+	if err := checkLength(NameFrequency, n, minBits); err != nil {
+		return Result{}, err
+	}
+	s := 0
+	for _, b := range e {
+		s += 2*int(b) - 1
+	}
+	obs := math.Abs(float64(s)) / math.Sqrt(float64(n))
+	return Result{Name: NameFrequency, PValues: []float64{math.Erfc(obs / math.Sqrt2)}}, nil
+}
+
+// BlockFrequency is the frequency test within m-bit blocks (SP 800-22
+// 2.2).
+func BlockFrequency(data []byte, m int) (Result, error) {
+	return blockFrequency(unpack(data), m)
+}
+
+func blockFrequency(e []uint8, m int) (Result, error) {
+	n := len(e)
+	if err := checkLength(NameBlockFrequency, n, minBits); err != nil {
+		return Result{}, err
+	}
+	if m < 1 || m > n {
+		return Result{}, fmt.Errorf("%s: invalid block size %d for %d bits", NameBlockFrequency, m, n)
+	}
+	blocks := n / m
+	chi := 0.0
+	for i := 0; i < blocks; i++ {
+		ones := 0
+		for _, b := range e[i*m : (i+1)*m] {
+			ones += int(b)
+		}
+		d := float64(ones)/float64(m) - 0.5
+		chi += d * d
+	}
+	chi *= 4 * float64(m)
+	return Result{Name: NameBlockFrequency, PValues: []float64{igamc(float64(blocks)/2, chi/2)}}, nil
+}
+
+// Runs counts uninterrupted runs of identical bits (SP 800-22 2.3). The
+// p-value is 0 when the sequence fails the frequency prerequisite.
+func Runs(data []byte) (Result, error) {
+	return runs(unpack(data))
+}
+
+func runs(e []uint8) (Result, error) {
+	n := len(e)
+	if err := checkLength(NameRuns, n, minBits); err != nil {
+		return Result{}, err
+	}
+	ones := 0
+	for _, b := range e {
+		ones += int(b)
+	}
+	pi := float64(ones) / float64(n)
+	if math.Abs(pi-0.5) >= 2/math.Sqrt(float64(n)) {
+		return Result{Name: NameRuns, PValues: []float64{0}}, nil
+	}
+	v := 1
+	for i := 1; i < n; i++ {
+		if e[i] != e[i-1] {
+			v++
+		}
+	}
+	q := pi * (1 - pi)
+	obs := math.Abs(float64(v)-2*float64(n)*q) / (2 * math.Sqrt(2*float64(n)) * q)
+	return Result{Name: NameRuns, PValues: []float64{math.Erfc(obs)}}, nil
+}
+
+// longestRunTable holds the SP 800-22 2.4 parameters for one block size:
+// run lengths at or below the first class and at or above the last are
+// pooled.
+type longestRunTable struct {
+	minBits int
+	m       int
+	first   int
+	pi      []float64
+}
+
+var longestRunTables = []longestRunTable{
+	{750000, 10000, 10, []float64{0.0882, 0.2092, 0.2483, 0.1933, 0.1208, 0.0675, 0.0727}},
+	{6272, 128, 4, []float64{0.1174, 0.2430, 0.2493, 0.1752, 0.1027, 0.1124}},
+	{128, 8, 1, []float64{0.2148, 0.3672, 0.2305, 0.1875}},
+}
+
+// LongestRun is the test for the longest run of ones in a block (SP 800-22
+// 2.4). The block size follows from the sequence length.
+func LongestRun(data []byte) (Result, error) {
+	return longestRun(unpack(data))
+}
+
+func longestRun(e []uint8) (Result, error) {
+	n := len(e)
+	var t longestRunTable
+	for _, t = range longestRunTables {
+		if n >= t.minBits {
+			break
+		}
+	}
+	if err := checkLength(NameLongestRun, n, t.minBits); err != nil {
+		return Result{}, err
+	}
+
+	k := len(t.pi) - 1
+	v := make([]int, len(t.pi))
+	blocks := n / t.m
+	for i := 0; i < blocks; i++ {
+		longest, run := 0, 0
+		for _, b := range e[i*t.m : (i+1)*t.m] {
+			if b == 1 {
+				run++
+				longest = max(longest, run)
+			} else {
+				run = 0
+			}
+		}
+		v[min(max(longest-t.first, 0), k)]++
+	}
+
+	chi := 0.0
+	for i, p := range t.pi {
+		exp := float64(blocks) * p
+		d := float64(v[i]) - exp
+		chi += d * d / exp
+	}
+	return Result{Name: NameLongestRun, PValues: []float64{igamc(float64(k)/2, chi/2)}}, nil
+}
+
+// patternCounts counts the overlapping m-bit patterns of e, wrapping
+// around its end.
+func patternCounts(e []uint8, m int) []int {
+	counts := make([]int, 1<<m)
+	if m == 0 {
+		return counts
+	}
+	n := len(e)
+	mask := 1<<m - 1
+	w := 0
+	for i := 0; i < m-1; i++ {
+		w = w<<1 | int(e[i])
+	}
+	for i := 0; i < n; i++ {
+		w = (w<<1 | int(e[(i+m-1)%n])) & mask
+		counts[w]++
+	}
+	return counts
+}
+
+// Serial compares the frequencies of all overlapping m-bit patterns (SP
+// 800-22 2.11). It reports the p-values of the first and second
+// differences.
+func Serial(data []byte, m int) (Result, error) {
+	return serial(unpack(data), m)
+}
+
+func serial(e []uint8, m int) (Result, error) {
+	n := len(e)
+	if err := checkLength(NameSerial, n, minBits); err != nil {
+		return Result{}, err
+	}
+	if m < 3 || m > 24 || m >= n {
+		return Result{}, fmt.Errorf("%s: invalid pattern length %d for %d bits", NameSerial, m, n)
+	}
+	psi := func(m int) float64 {
+		if m <= 0 {
+			return 0
+		}
+		sum := 0.0
+		for _, c := range patternCounts(e, m) {
+			sum += float64(c) * float64(c)
+		}
+		return sum*math.Ldexp(1, m)/float64(n) - float64(n)
+	}
+	p0, p1, p2 := psi(m), psi(m-1), psi(m-2)
+	d1 := p0 - p1
+	d2 := p0 - 2*p1 + p2
+	return Result{Name: NameSerial, PValues: []float64{
+		igamc(math.Ldexp(1, m-2), d1/2),
+		igamc(math.Ldexp(1, m-3), d2/2),
+	}}, nil
+}
+
+// ApproximateEntropy compares the frequencies of overlapping m-bit and
+// (m+1)-bit patterns (SP 800-22 2.12).
+func ApproximateEntropy(data []byte, m int) (Result, error) {
+	return approximateEntropy(unpack(data), m)
+}
+
+func approximateEntropy(e []uint8, m int) (Result, error) {
+	n := len(e)
+	if err := checkLength(NameApproximateEntropy, n, minBits); err != nil {
+		return Result{}, err
+	}
+	if m < 1 || m > 24 || m >= n {
+		return Result{}, fmt.Errorf("%s: invalid pattern length %d for %d bits", NameApproximateEntropy, m, n)
+	}
+	phi := func(m int) float64 {
+		sum := 0.0
+		for _, c := range patternCounts(e, m) {
+			if c > 0 {
+				p := float64(c) / float64(n)
+				sum += p * math.Log(p)
+			}
+		}
+		return sum
+	}
+	apEn := phi(m) - phi(m+1)
+	chi := 2 * float64(n) * (math.Ln2 - apEn)
+	return Result{Name: NameApproximateEntropy, PValues: []float64{igamc(math.Ldexp(1, m-1), chi/2)}}, nil
+}
+
+// CumulativeSums is the cumulative sums test (SP 800-22 2.13). It reports
+// the p-values of the forward and backward random walks.
+func CumulativeSums(data []byte) (Result, error) {
+	return cumulativeSums(unpack(data))
+}
+
+func cumulativeSums(e []uint8) (Result, error) {
+	n := len(e)
+	if err := checkLength(NameCumulativeSums, n, minBits); err != nil {
+		return Result{}, err
+	}
+	forward, backward := 0, 0
+	sum, total := 0, 0
+	for _, b := range e {
+		total += 2*int(b) - 1
+	}
+	for _, b := range e {
+		sum += 2*int(b) - 1
+		forward = max(forward, abs(sum))
+		// The backward walk's partial sums are total minus the forward
+		// ones, shifted by one step.
+		backward = max(backward, abs(total-sum+2*int(b)-1))
+	}
+	return Result{Name: NameCumulativeSums, PValues: []float64{
+		cusumPValue(n, forward),
+		cusumPValue(n, backward),
+	}}, nil
+}
+
+// cusumPValue returns the p-value for maximum excursion z of an n-step
+// walk.
+func cusumPValue(n, z int) float64 {
+	fn, fz := float64(n), float64(z)
+	sqrtN := math.Sqrt(fn)
+	sum1 := 0.0
+	for k := math.Trunc((-fn/fz + 1) / 4); k <= math.Trunc((fn/fz-1)/4); k++ {
+		sum1 += normalCDF((4*k+1)*fz/sqrtN) - normalCDF((4*k-1)*fz/sqrtN)
+	}
+	sum2 := 0.0
+	for k := math.Trunc((-fn/fz - 3) / 4); k <= math.Trunc((fn/fz-1)/4); k++ {
+		sum2 += normalCDF((4*k+3)*fz/sqrtN) - normalCDF((4*k+1)*fz/sqrtN)
+	}
+	return 1 - sum1 + sum2
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// ChiSquareBytes tests whether the 256 byte values occur equally often.
+// It needs at least five expected occurrences per value.
+func ChiSquareBytes(data []byte) (Result, error) {
+	if err := checkLength(NameChiSquareBytes, len(data)*8, 256*5*8); err != nil {
+		return Result{}, err
+	}
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+	exp := float64(len(data)) / 256
+	chi := 0.0
+	for _, c := range counts {
+		d := float64(c) - exp
+		chi += d * d / exp
+	}
+	return Result{Name: NameChiSquareBytes, PValues: []float64{igamc(255.0/2, chi/2)}}, nil
+}
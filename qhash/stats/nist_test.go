@@ -0,0 +1,119 @@
+// =======================
+// qhash/stats/nist_test.go
+// =======================
+
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+// The worked examples of SP 800-22 rev. 1a, section 2. epsilon100 is the
+// first 100 bits of the binary expansion of pi.
+const (
+	epsilon100 = "11001001000011111101101010100010001000010110100011" +
+		"00001000110100110001001100011001100010100010111000"
+	epsilon128 = "11001100000101010110110001001100111000000000001001" +
+		"00110101010001000100111101011010000000110101111100" +
+		"1100111001101101100010110010"
+)
+
+func bitString(s string) []uint8 {
+	e := make([]uint8, len(s))
+	for i := 0; i < len(s); i++ {
+		e[i] = s[i] - '0'
+	}
+	return e
+}
+
+// pack is the inverse of unpack for whole bytes.
+func pack(e []uint8) []byte {
+	data := make([]byte, len(e)/8)
+	for i, b := range e {
+		data[i/8] |= b << (7 - i%8)
+	}
+	return data
+}
+
+func checkPValues(t *testing.T, r Result, err error, want ...float64) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.PValues) != len(want) {
+		t.Fatalf("%s: %d p-values, want %d", r.Name, len(r.PValues), len(want))
+	}
+	for i, p := range r.PValues {
+		// The examples are rounded to six places
+		if math.Abs(p-want[i]) > 1e-6 {
+			t.Errorf("%s: p-value %d is %.6f, want %.6f", r.Name, i, p, want[i])
+		}
+	}
+}
+
+func TestFrequency(t *testing.T) {
+	r, err := frequency(bitString(epsilon100))
+	checkPValues(t, r, err, 0.109599)
+}
+
+func TestBlockFrequency(t *testing.T) {
+	r, err := blockFrequency(bitString(epsilon100), 10)
+	checkPValues(t, r, err, 0.706438)
+}
+
+func TestRuns(t *testing.T) {
+	r, err := runs(bitString(epsilon100))
+	checkPValues(t, r, err, 0.500798)
+}
+
+func TestLongestRun(t *testing.T) {
+	// The example prints 0.180609, but its own chi-square of 4.882605
+	// gives igamc(3/2, 4.882605/2) = 0.180598.
+	r, err := LongestRun(pack(bitString(epsilon128)))
+	checkPValues(t, r, err, 0.180598)
+}
+
+func TestApproximateEntropy(t *testing.T) {
+	r, err := approximateEntropy(bitString(epsilon100), 2)
+	checkPValues(t, r, err, 0.235301)
+}
+
+func TestCumulativeSums(t *testing.T) {
+	r, err := cumulativeSums(bitString(epsilon100))
+	checkPValues(t, r, err, 0.219194, 0.114866)
+}
+
+func TestChiSquareBytes(t *testing.T) {
+	uniform := make([]byte, 256*8)
+	for i := range uniform {
+		uniform[i] = byte(i)
+	}
+	r, err := ChiSquareBytes(uniform)
+	checkPValues(t, r, err, 1)
+
+	constant := make([]byte, 256*8)
+	if r, err := ChiSquareBytes(constant); err != nil || r.Pass(Alpha) {
+		t.Errorf("constant bytes: %v, %v", r.PValues, err)
+	}
+
+	if _, err := ChiSquareBytes(uniform[:256*5-1]); err == nil {
+		t.Error("fewer than five expected occurrences per value accepted")
+	}
+}
+
+func TestSequenceLength(t *testing.T) {
+	e := bitString(epsilon100)[:minBits-1]
+	if _, err := frequency(e); err == nil {
+		t.Error("Frequency accepted a short sequence")
+	}
+	if _, err := LongestRun(pack(bitString(epsilon128))[:15]); err == nil {
+		t.Error("LongestRun accepted a short sequence")
+	}
+	if _, err := Battery(make([]byte, MinBits/8-1)); err == nil {
+		t.Error("Battery accepted a short sequence")
+	}
+	if _, err := Battery(make([]byte, MaxBits/8+1)); err == nil {
+		t.Error("Battery accepted a sequence over MaxBits")
+	}
+}
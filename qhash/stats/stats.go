@@ -0,0 +1,90 @@
+// =======================
+// qhash/stats/stats.go
+// =======================
+
+// Package stats implements statistical randomness tests for hash and stream
+// output: the core tests of NIST SP 800-22 and a chi-square test of the byte
+// distribution. Sequences are byte slices read most significant bit first.
+// The tests find gross bias only; passing them says nothing about security.
+package stats
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// Alpha is the significance level recommended by SP 800-22. A sequence
+// fails a test when a p-value falls below it.
+const Alpha = 0.01
+
+// MinBits is the shortest sequence Battery accepts.
+const MinBits = 1 << 14
+
+// MaxBits is the longest sequence Battery accepts. The tests hold one byte
+// per bit, so a full-length run needs about 64 MiB.
+const MaxBits = 1 << 26
+
+// Result is the outcome of one test. Serial and CumulativeSums report two
+// p-values; the others report one.
+type Result struct {
+	Name    string    `json:"name"`
+	PValues []float64 `json:"p_values"`
+}
+
+// Pass reports whether every p-value is at least alpha.
+func (r Result) Pass(alpha float64) bool {
+	for _, p := range r.PValues {
+		if p < alpha {
+			return false
+		}
+	}
+	return true
+}
+
+// Battery runs every test on data with parameters chosen for its length.
+func Battery(data []byte) ([]Result, error) {
+	n := len(data) * 8
+	if n < MinBits {
+		return nil, fmt.Errorf("sequence too short: %d bits, need %d", n, MinBits)
+	}
+	if n > MaxBits {
+		return nil, fmt.Errorf("sequence too long: %d bits, limit %d", n, MaxBits)
+	}
+	// SP 800-22 section 2: M > 0.01n and fewer than 100 blocks
+	blockSize := max(128, n/99+1)
+	// m < floor(log2 n) - 2 for Serial, - 5 for ApproximateEntropy
+	log2n := bits.Len(uint(n)) - 1
+	serialM := min(16, log2n-3)
+	entropyM := min(10, log2n-6)
+
+	tests := []func([]byte) (Result, error){
+		Frequency,
+		func(d []byte) (Result, error) { return BlockFrequency(d, blockSize) },
+		Runs,
+		LongestRun,
+		func(d []byte) (Result, error) { return Serial(d, serialM) },
+		func(d []byte) (Result, error) { return ApproximateEntropy(d, entropyM) },
+		CumulativeSums,
+		ChiSquareBytes,
+	}
+	results := make([]Result, 0, len(tests))
+	for _, test := range tests {
+		r, err := test(data)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// unpack returns the bits of data, most significant bit first.
+func unpack(data []byte) []uint8 {
+	out := make([]uint8, 0, len(data)*8)
+	for _, b := range data {
+		for i := 7; i >= 0; i-- {
+			out = append(out, b>>i&1)
+		}
+	}
+	return out
+}
@@ -0,0 +1,232 @@
+// =======================
+// qhash/stream.go
+// =======================
+
+package qhash
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"math/rand"
+)
+
+// StreamReseedInterval is the number of bytes a Stream emits between
+// reseeds.
+const StreamReseedInterval = 64 << 10
+
+const streamDomain = "QHASH-STREAM"
+
+// streamFinalizeVersion is the final mixing version that derives Stream keys.
+// It stays fixed when AlgorithmVersion moves on, so a seed keeps yielding
+// the same stream.
+const streamFinalizeVersion = "2.3"
+
+var (
+	_ io.Reader     = (*Stream)(nil)
+	_ rand.Source64 = (*Stream)(nil)
+)
+
+// Stream is an experimental pseudorandom generator that keeps integrating a
+// Lorenz trajectory and emits its discretized coordinates. Every
+// StreamReseedInterval bytes the key is replaced by quantumFinalize of the
+// old key and the current state, and the trajectory restarts from the new
+// key, so output from before a reseed cannot be recovered from a later
+// state.
+//
+// Output is whitened: every 64-byte block is SHA-512 over the key, a block
+// counter and the coordinates of keystreamStepsPerBlock steps, so blocks do
+// not expose the trajectory state. It is meant for comparison with
+// crypto/rand in simulations; use crypto/rand for keys. A Stream is not safe
+// for concurrent use.
+type Stream struct {
+	key     []byte
+	salt    *HierarchicalSalt
+	ks      *keystream
+	emitted int // bytes since the last reseed
+	err     error
+}
+
+// NewStream returns a Stream seeded by seed. Equal seeds yield equal
+// streams.
+//
+// Read returns an error if the trajectory fails, which the Lorenz parameters
+// used here are not known to cause. Uint64, Int63 and Seed cannot report
+// errors through the math/rand interfaces and panic instead.
+func NewStream(seed []byte) (*Stream, error) {
+	salt, err := DeriveSaltHierarchy([]byte(streamDomain), 1, int(Size256))
+	if err != nil {
+		return nil, err
+	}
+	s := &Stream{salt: salt}
+	if err := s.reset(seed); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// reset replaces the key with one derived from seed and restarts the
+// trajectory from it.
+func (s *Stream) reset(seed []byte) error {
+	if len(seed) == 0 {
+		return fmt.Errorf("empty seed")
+	}
+	key, err := quantumFinalize(context.Background(), BigFloatEngine{},
+		seed, s.salt, Size256, streamFinalizeVersion)
+	if err != nil {
+		return fmt.Errorf("reseed failed: %w", err)
+	}
+	clear(s.key)
+	s.key = key
+
+	s.ks, err = newKeystream(key, s.salt.MasterSalt)
+	if err != nil {
+		return err
+	}
+	s.emitted, s.err = 0, nil
+	return nil
+}
+
+// step refills the keystream buffer, reseeding first when due.
+func (s *Stream) step() error {
+	if s.emitted >= StreamReseedInterval {
+		seed := append([]byte{}, s.key...)
+		for _, v := range s.ks.state {
+			seed = append(seed, v.Text('g', 40)...)
+		}
+		if err := s.reset(seed); err != nil {
+			return err
+		}
+	}
+
+	if err := s.ks.step(); err != nil {
+		return err
+	}
+	s.emitted += len(s.ks.buf)
+	return nil
+}
+
+// Read fills p with the next len(p) bytes of the stream.
+func (s *Stream) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(s.ks.buf) == 0 {
+			if s.err == nil {
+				s.err = s.step()
+			}
+			if s.err != nil {
+				return n, s.err
+			}
+		}
+		c := copy(p[n:], s.ks.buf)
+		s.ks.buf = s.ks.buf[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// Uint64 returns the next 8 bytes of the stream as a big-endian integer. It
+// panics if the trajectory fails, which the Source interfaces cannot report.
+func (s *Stream) Uint64() uint64 {
+	var b [8]byte
+	if _, err := io.ReadFull(s, b[:]); err != nil {
+		panic(fmt.Sprintf("qhash: stream failed: %v", err))
+	}
+	return binary.BigEndian.Uint64(b[:])
+}
+
+// Int63 returns a non-negative 63-bit integer, for math/rand.
+func (s *Stream) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+// Seed restarts the stream from the big-endian encoding of seed, for
+// math/rand. It panics if reseeding fails.
+func (s *Stream) Seed(seed int64) {
+	if err := s.reset(binary.BigEndian.AppendUint64(nil, uint64(seed))); err != nil {
+		panic(fmt.Sprintf("qhash: stream failed: %v", err))
+	}
+}
+
+// keystreamStepsPerBlock is the number of Lorenz steps squeezed into every
+// keystream block.
+const keystreamStepsPerBlock = 4
+
+// keystream squeezes a Lorenz trajectory into whitened blocks: block i is
+// SHA-512(key || uint64 BE i || coordinates of the next
+// keystreamStepsPerBlock steps).
+type keystream struct {
+	key     []byte
+	state   []*big.Float
+	f       Derivative
+	shifts  []int
+	counter uint64
+	buf     []byte
+}
+
+// newKeystream seeds a Lorenz trajectory from seed and salt, runs its
+// warm-up and whitens its blocks with seed||salt.
+func newKeystream(seed, salt []byte) (*keystream, error) {
+	sys := Lorenz{}
+	state, err := seedState(seed, salt, sys.Dim(), sys.Radius())
+	if err != nil {
+		return nil, fmt.Errorf("seed generation failed: %w", err)
+	}
+	k := &keystream{
+		key:    append(append([]byte{}, seed...), salt...),
+		state:  state,
+		f:      sys.Derivative(sys.DefaultParams()),
+		shifts: extractionShifts(128),
+	}
+	for i := 0; i < xofDiscard; i++ {
+		if err := integrateStep(Euler{}, k.state, k.f, xofDt); err != nil {
+			return nil, fmt.Errorf("warm-up step %d failed: %w", i, err)
+		}
+	}
+	return k, nil
+}
+
+// step advances the trajectory and replaces buf with the next block.
+func (k *keystream) step() error {
+	traj := make([]byte, 0, keystreamStepsPerBlock*len(k.state)*len(k.shifts))
+	for i := 0; i < keystreamStepsPerBlock; i++ {
+		if err := integrateStep(Euler{}, k.state, k.f, xofDt); err != nil {
+			return fmt.Errorf("block %d: %w", k.counter, err)
+		}
+		for _, shift := range k.shifts {
+			for j, v := range k.state {
+				b, err := discretizeWithShift(v, shift)
+				if err != nil {
+					return fmt.Errorf("%c discretization failed: %w", coordNames[j], err)
+				}
+				traj = append(traj, b)
+			}
+		}
+	}
+
+	block := sha512.New()
+	block.Write(k.key)
+	block.Write(binary.BigEndian.AppendUint64(nil, k.counter))
+	block.Write(traj)
+	k.buf = block.Sum(nil)
+	k.counter++
+	return nil
+}
+
+// xor sets dst to src XOR the next len(src) keystream bytes. dst and src
+// may overlap exactly.
+func (k *keystream) xor(dst, src []byte) error {
+	for i := range src {
+		if len(k.buf) == 0 {
+			if err := k.step(); err != nil {
+				return err
+			}
+		}
+		dst[i] = src[i] ^ k.buf[0]
+		k.buf = k.buf[1:]
+	}
+	return nil
+}
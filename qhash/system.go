@@ -0,0 +1,326 @@
+// =======================
+// qhash/system.go
+// =======================
+
+package qhash
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// ChaoticSystem is an autonomous system of ODEs a stage can integrate.
+type ChaoticSystem interface {
+	Name() string
+	// Dim is the state dimension, at most 4.
+	Dim() int
+	// Radius bounds the box [-Radius, Radius)^Dim initial conditions are
+	// drawn from.
+	Radius() float64
+	// TimeScale multiplies the stage step size. It adapts step sizes tuned
+	// for Lorenz to the system's speed and explicit-Euler stability limit.
+	TimeScale() float64
+	DefaultParams() []*big.Float
+	// Validate checks a parameter vector for DefaultParams' layout.
+	Validate(params []*big.Float) error
+	Derivative(params []*big.Float) Derivative
+}
+
+// Chaotic systems
+const (
+	SystemLorenz      = "lorenz"
+	SystemRossler     = "rossler"
+	SystemChen        = "chen"
+	SystemLu          = "lu"
+	SystemThomas      = "thomas"
+	SystemHyperLorenz = "hyperlorenz"
+)
+
+// DefaultSystem is used for stages that do not select one.
+const DefaultSystem = SystemLorenz
+
+var systems = map[string]ChaoticSystem{
+	SystemLorenz:      Lorenz{},
+	SystemRossler:     Rossler{},
+	SystemChen:        Chen{},
+	SystemLu:          Lu{},
+	SystemThomas:      Thomas{},
+	SystemHyperLorenz: HyperLorenz{},
+}
+
+// LookupSystem returns the system registered under name. An empty name
+// selects DefaultSystem.
+func LookupSystem(name string) (ChaoticSystem, error) {
+	if name == "" {
+		name = DefaultSystem
+	}
+	sys, ok := systems[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown chaotic system: %s", name)
+	}
+	return sys, nil
+}
+
+// checkParams checks that params[i] lies in (lo[i], hi[i]] for every i.
+func checkParams(params []*big.Float, names []string, lo, hi []float64) error {
+	if len(params) != len(names) {
+		return fmt.Errorf("expected %d parameters, got %d", len(names), len(params))
+	}
+	for i, p := range params {
+		if p == nil {
+			return fmt.Errorf("nil parameters")
+		}
+		if v, _ := p.Float64(); v <= lo[i] || v > hi[i] {
+			return fmt.Errorf("%s parameter out of range: %f", names[i], v)
+		}
+	}
+	return nil
+}
+
+func bigFloats(vs ...float64) []*big.Float {
+	out := make([]*big.Float, len(vs))
+	for i, v := range vs {
+		out[i] = big.NewFloat(v).SetPrec(128)
+	}
+	return out
+}
+
+// Lorenz is the classic Lorenz system with parameters sigma, rho, beta.
+type Lorenz struct{}
+
+func (Lorenz) Name() string                { return SystemLorenz }
+func (Lorenz) Dim() int                    { return 3 }
+func (Lorenz) Radius() float64             { return 20 }
+func (Lorenz) TimeScale() float64          { return 1 }
+func (Lorenz) DefaultParams() []*big.Float { return bigFloats(10, 28, 8.0/3.0) }
+
+func (Lorenz) Validate(params []*big.Float) error {
+	return checkParams(params,
+		[]string{"sigma", "rho", "beta"}, []float64{0, 0, 0}, []float64{100, 100, 100})
+}
+
+func (Lorenz) Derivative(params []*big.Float) Derivative {
+	sigma, rho, beta := params[0], params[1], params[2]
+	return func(s []*big.Float) []*big.Float {
+		x, y, z := s[0], s[1], s[2]
+		dx := new(big.Float).Mul(sigma, new(big.Float).Sub(y, x))
+		dy := new(big.Float).Sub(
+			new(big.Float).Mul(x, new(big.Float).Sub(rho, z)),
+			y,
+		)
+		dz := new(big.Float).Sub(
+			new(big.Float).Mul(x, y),
+			new(big.Float).Mul(beta, z),
+		)
+		return []*big.Float{dx, dy, dz}
+	}
+}
+
+// Rossler is the Rössler system with parameters a, b, c:
+//
+//	x' = -y - z,  y' = x + a*y,  z' = b + z*(x - c)
+type Rossler struct{}
+
+func (Rossler) Name() string                { return SystemRossler }
+func (Rossler) Dim() int                    { return 3 }
+func (Rossler) Radius() float64             { return 2 }
+func (Rossler) TimeScale() float64          { return 4 }
+func (Rossler) DefaultParams() []*big.Float { return bigFloats(0.2, 0.2, 5.7) }
+
+func (Rossler) Validate(params []*big.Float) error {
+	return checkParams(params,
+		[]string{"a", "b", "c"}, []float64{0, 0, 0}, []float64{1, 2, 20})
+}
+
+func (Rossler) Derivative(params []*big.Float) Derivative {
+	a, b, c := params[0], params[1], params[2]
+	return func(s []*big.Float) []*big.Float {
+		x, y, z := s[0], s[1], s[2]
+		dx := new(big.Float).Sub(new(big.Float).Neg(y), z)
+		dy := new(big.Float).Add(x, new(big.Float).Mul(a, y))
+		dz := new(big.Float).Add(b,
+			new(big.Float).Mul(z, new(big.Float).Sub(x, c)))
+		return []*big.Float{dx, dy, dz}
+	}
+}
+
+// Chen is the Chen system with parameters a, b, c:
+//
+//	x' = a*(y - x),  y' = (c - a)*x - x*z + c*y,  z' = x*y - b*z
+type Chen struct{}
+
+func (Chen) Name() string                { return SystemChen }
+func (Chen) Dim() int                    { return 3 }
+func (Chen) Radius() float64             { return 20 }
+func (Chen) TimeScale() float64          { return 0.03 }
+func (Chen) DefaultParams() []*big.Float { return bigFloats(35, 3, 28) }
+
+func (Chen) Validate(params []*big.Float) error {
+	return checkParams(params,
+		[]string{"a", "b", "c"}, []float64{0, 0, 0}, []float64{100, 100, 100})
+}
+
+func (Chen) Derivative(params []*big.Float) Derivative {
+	a, b, c := params[0], params[1], params[2]
+	return func(s []*big.Float) []*big.Float {
+		x, y, z := s[0], s[1], s[2]
+		dx := new(big.Float).Mul(a, new(big.Float).Sub(y, x))
+		dy := new(big.Float).Mul(new(big.Float).Sub(c, a), x)
+		dy.Sub(dy, new(big.Float).Mul(x, z))
+		dy.Add(dy, new(big.Float).Mul(c, y))
+		dz := new(big.Float).Sub(
+			new(big.Float).Mul(x, y),
+			new(big.Float).Mul(b, z),
+		)
+		return []*big.Float{dx, dy, dz}
+	}
+}
+
+// Lu is the Lü system with parameters a, b, c:
+//
+//	x' = a*(y - x),  y' = c*y - x*z,  z' = x*y - b*z
+type Lu struct{}
+
+func (Lu) Name() string                { return SystemLu }
+func (Lu) Dim() int                    { return 3 }
+func (Lu) Radius() float64             { return 20 }
+func (Lu) TimeScale() float64          { return 0.15 }
+func (Lu) DefaultParams() []*big.Float { return bigFloats(36, 3, 20) }
+
+func (Lu) Validate(params []*big.Float) error {
+	return checkParams(params,
+		[]string{"a", "b", "c"}, []float64{0, 0, 0}, []float64{100, 100, 100})
+}
+
+func (Lu) Derivative(params []*big.Float) Derivative {
+	a, b, c := params[0], params[1], params[2]
+	return func(s []*big.Float) []*big.Float {
+		x, y, z := s[0], s[1], s[2]
+		dx := new(big.Float).Mul(a, new(big.Float).Sub(y, x))
+		dy := new(big.Float).Sub(
+			new(big.Float).Mul(c, y),
+			new(big.Float).Mul(x, z),
+		)
+		dz := new(big.Float).Sub(
+			new(big.Float).Mul(x, y),
+			new(big.Float).Mul(b, z),
+		)
+		return []*big.Float{dx, dy, dz}
+	}
+}
+
+// Thomas is Thomas' cyclically symmetric attractor with damping b:
+//
+//	x' = sin(y) - b*x,  y' = sin(z) - b*y,  z' = sin(x) - b*z
+type Thomas struct{}
+
+func (Thomas) Name() string                { return SystemThomas }
+func (Thomas) Dim() int                    { return 3 }
+func (Thomas) Radius() float64             { return 5 }
+func (Thomas) TimeScale() float64          { return 10 }
+func (Thomas) DefaultParams() []*big.Float { return bigFloats(0.208186) }
+
+func (Thomas) Validate(params []*big.Float) error {
+	return checkParams(params, []string{"b"}, []float64{0}, []float64{1})
+}
+
+func (Thomas) Derivative(params []*big.Float) Derivative {
+	b := params[0]
+	return func(s []*big.Float) []*big.Float {
+		out := make([]*big.Float, 3)
+		for i := range out {
+			out[i] = new(big.Float).Sub(
+				sinBig(s[(i+1)%3]),
+				new(big.Float).Mul(b, s[i]),
+			)
+		}
+		return out
+	}
+}
+
+// HyperLorenz is the 4D hyperchaotic Lorenz system with parameters a, b, c, r:
+//
+//	x' = a*(y - x) + w,  y' = c*x - y - x*z,  z' = x*y - b*z,  w' = r*w - y*z
+type HyperLorenz struct{}
+
+func (HyperLorenz) Name() string                { return SystemHyperLorenz }
+func (HyperLorenz) Dim() int                    { return 4 }
+func (HyperLorenz) Radius() float64             { return 20 }
+func (HyperLorenz) TimeScale() float64          { return 0.25 }
+func (HyperLorenz) DefaultParams() []*big.Float { return bigFloats(10, 8.0/3.0, 28, -1) }
+
+func (HyperLorenz) Validate(params []*big.Float) error {
+	return checkParams(params,
+		[]string{"a", "b", "c", "r"}, []float64{0, 0, 0, -10}, []float64{100, 100, 100, 0})
+}
+
+func (HyperLorenz) Derivative(params []*big.Float) Derivative {
+	a, b, c, r := params[0], params[1], params[2], params[3]
+	return func(s []*big.Float) []*big.Float {
+		x, y, z, w := s[0], s[1], s[2], s[3]
+		dx := new(big.Float).Mul(a, new(big.Float).Sub(y, x))
+		dx.Add(dx, w)
+		dy := new(big.Float).Mul(c, x)
+		dy.Sub(dy, y)
+		dy.Sub(dy, new(big.Float).Mul(x, z))
+		dz := new(big.Float).Sub(
+			new(big.Float).Mul(x, y),
+			new(big.Float).Mul(b, z),
+		)
+		dw := new(big.Float).Sub(
+			new(big.Float).Mul(r, w),
+			new(big.Float).Mul(y, z),
+		)
+		return []*big.Float{dx, dy, dz, dw}
+	}
+}
+
+// sinPrec leaves guard bits above the 128-bit result.
+const sinPrec = 160
+
+var (
+	piBig, _, _ = big.ParseFloat("3.14159265358979323846264338327950288419716939937510"+
+		"58209749445923078164062862089986280348253421170679", 10, sinPrec, big.ToNearestEven)
+	twoPiBig = new(big.Float).SetPrec(sinPrec).Mul(piBig, big.NewFloat(2))
+	sinEps   = new(big.Float).SetMantExp(big.NewFloat(1), -136)
+
+	// sinCoeffs[i] = -1 / ((2i+2)(2i+3)), the ratio of consecutive Taylor terms
+	sinCoeffs = func() []*big.Float {
+		c := make([]*big.Float, 64)
+		for i := range c {
+			n := int64(2*i+2) * int64(2*i+3)
+			c[i] = new(big.Float).SetPrec(sinPrec).Quo(big.NewFloat(-1), big.NewFloat(float64(n)))
+		}
+		return c
+	}()
+)
+
+// sinBig returns sin(x) rounded to 128 bits. math/big has no transcendental
+// functions, so it reduces x into [-pi, pi] and sums the Taylor series at
+// higher precision; the result depends only on big.Float arithmetic.
+func sinBig(x *big.Float) *big.Float {
+	r := new(big.Float).SetPrec(sinPrec).Set(x)
+	k := new(big.Float).SetPrec(sinPrec).Quo(r, twoPiBig)
+	n, _ := k.Int(nil)
+	k.SetInt(n)
+	r.Sub(r, k.Mul(k, twoPiBig))
+	if r.Cmp(piBig) > 0 {
+		r.Sub(r, twoPiBig)
+	} else if r.Cmp(new(big.Float).Neg(piBig)) < 0 {
+		r.Add(r, twoPiBig)
+	}
+
+	r2 := new(big.Float).SetPrec(sinPrec).Mul(r, r)
+	term := new(big.Float).SetPrec(sinPrec).Set(r)
+	sum := new(big.Float).SetPrec(sinPrec).Set(r)
+	abs := new(big.Float)
+	for _, c := range sinCoeffs {
+		if abs.Abs(term).Cmp(sinEps) <= 0 {
+			break
+		}
+		term.Mul(term, r2)
+		term.Mul(term, c)
+		sum.Add(sum, term)
+	}
+	return sum.SetPrec(128)
+}
@@ -1,17 +1,20 @@
 package qhash
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
 	"math/big"
 )
 
-// seedBig derives three big.Float values in [-20,20) from data||salt.
-func seedBig(data, salt []byte) (*big.Float, *big.Float, *big.Float, error) {
+// seedState derives dim big.Float values in [-radius,radius) from
+// data||salt. dim is at most 4.
+func seedState(data, salt []byte, dim int, radius float64) ([]*big.Float, error) {
 	if len(data) == 0 || len(salt) == 0 {
-		return nil, nil, nil, fmt.Errorf("empty input data")
+		return nil, fmt.Errorf("empty input data")
 	}
 
 	combined := make([]byte, 0, len(data)+len(salt))
@@ -22,161 +25,176 @@ func seedBig(data, salt []byte) (*big.Float, *big.Float, *big.Float, error) {
 	denom := new(big.Float).SetInt(new(big.Int).Lsh(big.NewInt(1), 64))
 	denom.SetPrec(128)
 
-	mk := func(off int) (*big.Float, error) {
+	state := make([]*big.Float, dim)
+	for i := range state {
+		off := i * 8
 		if off+8 > len(h) {
 			return nil, fmt.Errorf("insufficient hash bytes")
 		}
 		u := binary.BigEndian.Uint64(h[off : off+8])
 		f := new(big.Float).SetUint64(u).SetPrec(128) // [0,2^64)
 		f.Quo(f, denom)                               // [0,1)
-		f.Mul(f, big.NewFloat(40.0))                  // [0,40)
-		return f.Sub(f, big.NewFloat(20.0)), nil      // [-20,20)
+		f.Mul(f, big.NewFloat(2*radius))              // [0,2r)
+		state[i] = f.Sub(f, big.NewFloat(radius))     // [-r,r)
 	}
 
-	x, err := mk(0)
-	if err != nil {
-		return nil, nil, nil, err
-	}
-	y, err := mk(8)
-	if err != nil {
-		return nil, nil, nil, err
-	}
-	z, err := mk(16)
-	if err != nil {
-		return nil, nil, nil, err
-	}
+	return state, nil
+}
 
-	return x, y, z, nil
+// TrajectoryToHashBig evolves the Lorenz system in high precision with size-aware parameters.
+func TrajectoryToHashBig(
+	x0, y0, z0 *big.Float,
+	sigma, rho, beta, dt *big.Float,
+	iterations, discard, outSize int,
+) ([]byte, error) {
+	return trajectoryToHashBig(context.Background(), Euler{}, Lorenz{},
+		[]*big.Float{x0, y0, z0}, []*big.Float{sigma, rho, beta}, dt,
+		iterations, discard, 1, outSize)
 }
 
-// discretize extracts one byte from f by taking its fractional part.
-func discretize(f *big.Float) (byte, error) {
-	if f == nil {
-		return 0, fmt.Errorf("nil big.Float")
+// trajectoryToHashBig is TrajectoryToHashBig for any system, with a
+// selectable integrator, substeps integrator steps of dt/substeps per
+// extracted step and ctx checked on every step.
+func trajectoryToHashBig(
+	ctx context.Context,
+	integ Integrator,
+	sys ChaoticSystem,
+	state0, params []*big.Float,
+	dt *big.Float,
+	iterations, discard, substeps, outSize int,
+) ([]byte, error) {
+	if outSize <= 0 || outSize > 128 { // Max 1024 bits / 8 = 128 bytes
+		return nil, fmt.Errorf("invalid output size: %d", outSize)
 	}
 
-	// Copy f to avoid mutating caller's value
-	c := new(big.Float).Copy(f).SetPrec(128)
-
-	// Get integer part
-	intPart, _ := c.Int(nil) // truncates toward zero
-
-	// frac = c - intPart
-	frac := new(big.Float).Sub(c, new(big.Float).SetInt(intPart))
-
-	// Handle negative fractions
-	if frac.Sign() < 0 {
-		frac.Add(frac, big.NewFloat(1.0))
+	stream, err := trajectoryStream(ctx, integ, sys, state0, params, dt,
+		iterations, discard, substeps, extractionShifts(outSize))
+	if err != nil {
+		return nil, err
 	}
-
-	// Scale up fraction
-	frac.Mul(frac, big.NewFloat(256.0))
-
-	// Extract integer from scaled fraction
-	fracInt, _ := frac.Int(nil)
-	return byte(fracInt.Uint64() & 0xFF), nil
+	return foldStream(stream, outSize)
 }
 
-// TrajectoryToHashBig evolves the Lorenz system in high precision with size-aware parameters.
-func TrajectoryToHashBig(
-	x0, y0, z0 *big.Float,
-	sigma, rho, beta, dt *big.Float,
-	iterations, discard, outSize int,
+// trajectoryStream integrates sys from state0 and, after discard warm-up
+// steps, appends one byte per coordinate and shift on each of iterations
+// steps. Each step is made of substeps integrator steps of dt/substeps.
+func trajectoryStream(
+	ctx context.Context,
+	integ Integrator,
+	sys ChaoticSystem,
+	state0, params []*big.Float,
+	dt *big.Float,
+	iterations, discard, substeps int,
+	shifts []int,
 ) ([]byte, error) {
-	if x0 == nil || y0 == nil || z0 == nil || sigma == nil || rho == nil || beta == nil || dt == nil {
+	if integ == nil || sys == nil || dt == nil {
 		return nil, fmt.Errorf("nil parameters")
 	}
+	if len(state0) != sys.Dim() {
+		return nil, fmt.Errorf("%s state has %d coordinates, expected %d",
+			sys.Name(), len(state0), sys.Dim())
+	}
 
 	if iterations < MinIterations || iterations > MaxIterations {
 		return nil, fmt.Errorf("iterations out of safe range: %d", iterations)
 	}
 
-	if outSize <= 0 || outSize > 128 { // Max 1024 bits / 8 = 128 bytes
-		return nil, fmt.Errorf("invalid output size: %d", outSize)
-	}
-
 	// Enhanced parameter validation for stability
-	if s, _ := sigma.Float64(); s <= 0 || s > 100 {
-		return nil, fmt.Errorf("sigma parameter out of range: %f", s)
-	}
-	if r, _ := rho.Float64(); r <= 0 || r > 100 {
-		return nil, fmt.Errorf("rho parameter out of range: %f", r)
-	}
-	if b, _ := beta.Float64(); b <= 0 || b > 100 {
-		return nil, fmt.Errorf("beta parameter out of range: %f", b)
+	if err := sys.Validate(params); err != nil {
+		return nil, err
 	}
 	if d, _ := dt.Float64(); d <= 0 || d > 0.1 {
 		return nil, fmt.Errorf("dt parameter out of range: %f", d)
 	}
+	if scale := sys.TimeScale(); scale != 1 {
+		dt = new(big.Float).SetPrec(128).Mul(dt, big.NewFloat(scale))
+	}
+	if substeps < 1 {
+		substeps = 1
+	}
+	if substeps > 1 {
+		dt = new(big.Float).SetPrec(128).Quo(dt, new(big.Float).SetInt64(int64(substeps)))
+	}
 
 	// Initialize with copies to avoid mutation
-	x := new(big.Float).Copy(x0).SetPrec(128)
-	y := new(big.Float).Copy(y0).SetPrec(128)
-	z := new(big.Float).Copy(z0).SetPrec(128)
+	state := make([]*big.Float, len(state0))
+	for i, v := range state0 {
+		if v == nil {
+			return nil, fmt.Errorf("nil parameters")
+		}
+		state[i] = new(big.Float).Copy(v).SetPrec(128)
+	}
+	f := sys.Derivative(params)
+	step := func() error {
+		for k := 0; k < substeps; k++ {
+			if err := integrateStep(integ, state, f, dt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 
 	// Enhanced warm-up period to skip initial transients
 	for i := 0; i < discard; i++ {
-		if err := lorenzStep(x, y, z, sigma, rho, beta, dt); err != nil {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := step(); err != nil {
 			return nil, fmt.Errorf("warm-up step %d failed: %w", i, err)
 		}
 	}
 
 	// Generate stream with size-aware extraction strategy
-	streamMultiplier := int(math.Ceil(float64(outSize) / 32.0)) // Ensure enough entropy
-	if streamMultiplier < 1 {
-		streamMultiplier = 1
-	}
-
-	stream := make([]byte, 0, iterations*3*streamMultiplier)
+	stream := make([]byte, 0, iterations*len(state)*len(shifts))
 
 	for i := 0; i < iterations; i++ {
-		if err := lorenzStep(x, y, z, sigma, rho, beta, dt); err != nil {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := step(); err != nil {
 			return nil, fmt.Errorf("iteration %d failed: %w", i, err)
 		}
 
 		// Extract bytes from coordinates with enhanced entropy extraction
-		bx, err := discretize(x)
-		if err != nil {
-			return nil, fmt.Errorf("x discretization failed: %w", err)
-		}
-		by, err := discretize(y)
-		if err != nil {
-			return nil, fmt.Errorf("y discretization failed: %w", err)
-		}
-		bz, err := discretize(z)
-		if err != nil {
-			return nil, fmt.Errorf("z discretization failed: %w", err)
+		for _, shift := range shifts {
+			for j, v := range state {
+				b, err := discretizeWithShift(v, shift)
+				if err != nil {
+					return nil, fmt.Errorf("%c discretization failed: %w", coordNames[j], err)
+				}
+				stream = append(stream, b)
+			}
 		}
+	}
 
-		// For larger output sizes, extract more entropy per iteration
-		stream = append(stream, bx, by, bz)
+	return stream, nil
+}
 
-		// Additional entropy extraction for larger hash sizes
-		if outSize >= 48 { // 384+ bits
-			// Extract additional bytes from higher-order bits
-			bx2, _ := discretizeWithShift(x, 8)
-			by2, _ := discretizeWithShift(y, 8)
-			bz2, _ := discretizeWithShift(z, 8)
-			stream = append(stream, bx2, by2, bz2)
-		}
+// coordNames names state coordinates in error messages.
+const coordNames = "xyzw"
 
-		if outSize >= 64 { // 512+ bits
-			// Extract even more entropy
-			bx3, _ := discretizeWithShift(x, 16)
-			by3, _ := discretizeWithShift(y, 16)
-			bz3, _ := discretizeWithShift(z, 16)
-			stream = append(stream, bx3, by3, bz3)
-		}
+// ErrOverflow is wrapped by errors from trajectories that leave the bounded
+// region, which explicit integrators do when the step size is too large.
+var ErrOverflow = errors.New("coordinate overflow")
 
-		if outSize >= 128 { // 1024 bits
-			// Maximum entropy extraction
-			bx4, _ := discretizeWithShift(x, 24)
-			by4, _ := discretizeWithShift(y, 24)
-			bz4, _ := discretizeWithShift(z, 24)
-			stream = append(stream, bx4, by4, bz4)
-		}
+// extractionShifts lists the bit shifts at which a byte is taken from every
+// coordinate per iteration. Larger outputs extract more entropy per step.
+func extractionShifts(outSize int) []int {
+	shifts := []int{0}
+	if outSize >= 48 { // 384+ bits
+		shifts = append(shifts, 8)
+	}
+	if outSize >= 64 { // 512+ bits
+		shifts = append(shifts, 16)
 	}
+	if outSize >= 128 { // 1024 bits
+		shifts = append(shifts, 24)
+	}
+	return shifts
+}
 
+// foldStream compresses the extracted byte stream to outSize bytes.
+func foldStream(stream []byte, outSize int) ([]byte, error) {
 	if len(stream) == 0 {
 		return nil, fmt.Errorf("empty stream generated")
 	}
@@ -216,7 +234,8 @@ func TrajectoryToHashBig(
 	return hash, nil
 }
 
-// discretizeWithShift extracts a byte from f with a bit shift for additional entropy
+// discretizeWithShift extracts a byte from the fractional part of f*2^shift.
+// Shift 0 takes the first byte of f's own fractional part.
 func discretizeWithShift(f *big.Float, shift int) (byte, error) {
 	if f == nil {
 		return 0, fmt.Errorf("nil big.Float")
@@ -244,33 +263,17 @@ func discretizeWithShift(f *big.Float, shift int) (byte, error) {
 	return byte(fracInt.Uint64() & 0xFF), nil
 }
 
-// lorenzStep performs one step of Lorenz system evolution with enhanced stability checking
-func lorenzStep(x, y, z, sigma, rho, beta, dt *big.Float) error {
-	// Compute derivatives
-	dx := new(big.Float).Mul(sigma, new(big.Float).Sub(y, x))
-	dy := new(big.Float).Sub(
-		new(big.Float).Mul(x, new(big.Float).Sub(rho, z)),
-		y,
-	)
-	dz := new(big.Float).Sub(
-		new(big.Float).Mul(x, y),
-		new(big.Float).Mul(beta, z),
-	)
-
-	// Update coordinates
-	x.Add(x, new(big.Float).Mul(dx, dt))
-	y.Add(y, new(big.Float).Mul(dy, dt))
-	z.Add(z, new(big.Float).Mul(dz, dt))
+// integrateStep performs one integrator step with enhanced stability checking
+func integrateStep(integ Integrator, state []*big.Float, f Derivative, dt *big.Float) error {
+	if err := integ.Step(state, f, dt); err != nil {
+		return err
+	}
 
 	// Enhanced overflow/underflow checking
-	if xf, _ := x.Float64(); math.IsInf(xf, 0) || math.IsNaN(xf) || math.Abs(xf) > 1e10 {
-		return fmt.Errorf("x coordinate overflow: %f", xf)
-	}
-	if yf, _ := y.Float64(); math.IsInf(yf, 0) || math.IsNaN(yf) || math.Abs(yf) > 1e10 {
-		return fmt.Errorf("y coordinate overflow: %f", yf)
-	}
-	if zf, _ := z.Float64(); math.IsInf(zf, 0) || math.IsNaN(zf) || math.Abs(zf) > 1e10 {
-		return fmt.Errorf("z coordinate overflow: %f", zf)
+	for i, v := range state {
+		if f, _ := v.Float64(); math.IsInf(f, 0) || math.IsNaN(f) || math.Abs(f) > 1e10 {
+			return fmt.Errorf("%c %w: %f", coordNames[i], ErrOverflow, f)
+		}
 	}
 
 	return nil
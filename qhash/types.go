@@ -10,10 +10,26 @@ import (
 )
 
 const (
+	AlgorithmVersion      = "2.3"
+	HyperchaosVersion     = "2.2" // hyperchaos mix always integrated with big.Float
+	AdaptiveVersion       = "2.1" // adaptive parameters applied, XOR-only hyperchaos mix
+	LegacyVersion         = "2.0" // adaptive parameters recorded but not applied
 	MinComputeTime        = 100 * time.Millisecond
-	DefaultMemoryHardness = 512
+	DefaultMemoryHardness = 512    // KiB of memory-hard buffer
 	MaxIterations         = 100000 // Prevent DoS
 	MinIterations         = 1000
+	MaxTimeCost           = 28      // Keeps the longest stage under MaxIterations
+	MaxMemoryHardness     = 1 << 20 // KiB (1 GiB)
+	MaxParallelism        = 64
+	MaxLanes              = 64
+	MaxStages             = 10
+)
+
+// Trajectory engines
+const (
+	EngineBigFloat = "bigfloat" // 128-bit math/big.Float, the original engine
+	EngineFixed    = "fixed96"  // Q96 fixed-point math/big.Int, bit-exact
+	EngineFloat64  = "float64"  // IEEE-754 double, allocation-free and fast
 )
 
 // HashSize represents supported hash output sizes
@@ -26,12 +42,18 @@ const (
 	Size1024 HashSize = 1024
 )
 
-// LorenzStage represents one stage of multi-stage Lorenz computation
+// LorenzStage represents one stage of multi-stage Lorenz computation.
+// Sigma, Rho and Beta apply to Lorenz stages; stages running another
+// ChaoticSystem use Params, or its DefaultParams when Params is nil.
 type LorenzStage struct {
 	Sigma, Rho, Beta, Dt *big.Float
 	Iterations           int
-	StageID              int    `json:"stage_id"`
-	Description          string `json:"description"`
+	StageID              int          `json:"stage_id"`
+	Description          string       `json:"description"`
+	Integrator           string       `json:"integrator,omitempty"` // "" selects DefaultIntegrator
+	System               string       `json:"system,omitempty"`     // "" selects DefaultSystem
+	Params               []*big.Float `json:"params,omitempty"`
+	Discard              int          `json:"discard,omitempty"` // 0 selects the size default
 }
 
 type HierarchicalSalt struct {
@@ -40,10 +62,12 @@ type HierarchicalSalt struct {
 	TimestampSalt []byte   `json:"timestamp_salt"`
 	MetaSalt      []byte   `json:"meta_salt"`
 	HashSize      int      `json:"hash_size"`
+	EpochHour     int64    `json:"epoch_hour,omitempty"` // 0: not time-bound
 }
 
 type TrajectoryCheckpoint struct {
 	Stage     int    `json:"stage"`
+	Lane      int    `json:"lane,omitempty"`
 	Iteration int    `json:"iteration"`
 	Hash      string `json:"hash"`
 	Size      int    `json:"size"`
@@ -55,10 +79,18 @@ type HardenedSaltedHash struct {
 	Checkpoints []TrajectoryCheckpoint `json:"checkpoints"`
 	ComputeTime int64                  `json:"compute_time_ns"`
 	MemoryUsed  int                    `json:"memory_used_kb"`
-	Parameters  map[string]interface{} `json:"parameters"`
+	Parameters  *AdaptiveParameters    `json:"parameters"`
 	Algorithm   string                 `json:"algorithm"`
 	Version     string                 `json:"version"`
 	HashSize    int                    `json:"hash_size"`
+	TimeCost    int                    `json:"time_cost,omitempty"`
+	MemoryCost  int                    `json:"memory_cost_kb,omitempty"`
+	Parallelism int                    `json:"parallelism,omitempty"`
+	Lanes       int                    `json:"lanes,omitempty"`       // stage lanes, 0: one
+	Integrators []string               `json:"integrators,omitempty"` // per stage, nil: all DefaultIntegrator
+	Engine      string                 `json:"engine,omitempty"`      // "": EngineBigFloat
+	Systems     []string               `json:"systems,omitempty"`     // per stage, nil: all DefaultSystem
+	Config      string                 `json:"config,omitempty"`      // stage config fingerprint, "": built-in stages
 }
 
 type HardenedLorenzHasher struct {
@@ -66,4 +98,64 @@ type HardenedLorenzHasher struct {
 	memoryHardness int
 	minComputeTime time.Duration
 	hashSize       HashSize
+	key            []byte
+	timeCost       int
+	parallelism    int
+	lanes          int
+	clock          Clock
+	untimed        bool
+	engine         string
+	config         string // stage config fingerprint, "" for the built-in table
+}
+
+// Clock supplies the current time for binding salts to an epoch hour.
+type Clock func() time.Time
+
+// computeSpec selects how compute runs. Verification rebuilds it from the
+// stored hash so old hashes are recomputed the way they were produced.
+type computeSpec struct {
+	alg         algorithm
+	params      *AdaptiveParameters
+	cost        costParams
+	integrators []string // per stage, nil: all DefaultIntegrator
+	engine      string   // "": EngineBigFloat
+	systems     []string // per stage, nil: all DefaultSystem
+
+	// checkpoint, if set, receives every stage checkpoint as it is made;
+	// returning false aborts with errCheckpointMismatch before the
+	// memory-hard phase and the minimum compute time.
+	checkpoint func(TrajectoryCheckpoint) bool
+}
+
+// integrator returns the integrator name for stage idx.
+func (s computeSpec) integrator(idx int) string {
+	if idx < len(s.integrators) {
+		return s.integrators[idx]
+	}
+	return DefaultIntegrator
+}
+
+// system returns the chaotic system name for stage idx.
+func (s computeSpec) system(idx int) string {
+	if idx < len(s.systems) {
+		return s.systems[idx]
+	}
+	return DefaultSystem
+}
+
+// costParams are the tunable work factors applied by compute.
+type costParams struct {
+	time        int // stage iteration multiplier
+	memory      int // memory-hard buffer size in KiB, 0 disables the phase
+	parallelism int // independent memory lanes
+	lanes       int // independent stage lanes, run concurrently
+}
+
+// recordedLanes returns the lane count to store: 0 for the single lane
+// every older hash used.
+func (c costParams) recordedLanes() int {
+	if c.lanes <= 1 {
+		return 0
+	}
+	return c.lanes
 }
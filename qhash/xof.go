@@ -0,0 +1,118 @@
+// =======================
+// qhash/xof.go
+// =======================
+
+package qhash
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// XOFHashSize is the hash size NewXOF absorbs input with.
+const XOFHashSize = 512
+
+const (
+	xofDiscard       = 1000 // warm-up steps before the first block
+	xofStepsPerBlock = 16   // Lorenz steps squeezed into every output block
+)
+
+var xofDt = big.NewFloat(0.01).SetPrec(128)
+
+// xof squeezes output from a Lorenz trajectory seeded by a final hash. Each
+// 64-byte block is SHA-512(seed || counter || trajectory bytes), so output
+// is uniform and does not depend on how Reads are sized.
+type xof struct {
+	seed    []byte
+	state   []*big.Float
+	f       Derivative
+	counter uint64
+	buf     []byte
+	err     error
+}
+
+// NewXOF returns an extendable-output reader over data: every Read yields
+// further bytes of an unbounded deterministic stream, for deriving keys and
+// masks of any length. Input, which may be empty, is absorbed by a
+// XOFHashSize-bit hasher built with opts, so WithKey selects a keyed stream.
+func NewXOF(data []byte, opts ...Option) (io.Reader, error) {
+	h, err := NewHardenedLorenzHasher(XOFHashSize, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return h.NewXOF(data)
+}
+
+// NewXOF returns an extendable-output reader seeded by the digest of data
+// that NewDigest computes, without the memory-hard phase or the minimum
+// compute time.
+func (h *HardenedLorenzHasher) NewXOF(data []byte) (io.Reader, error) {
+	seed, err := h.HashReader(bytes.NewReader(data), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sys := Lorenz{}
+	state, err := seedState(seed, []byte("QHASH-XOF"), sys.Dim(), sys.Radius())
+	if err != nil {
+		return nil, fmt.Errorf("seed generation failed: %w", err)
+	}
+	x := &xof{
+		seed:  seed,
+		state: state,
+		f:     sys.Derivative(sys.DefaultParams()),
+	}
+	for i := 0; i < xofDiscard; i++ {
+		if err := integrateStep(Euler{}, x.state, x.f, xofDt); err != nil {
+			return nil, fmt.Errorf("warm-up step %d failed: %w", i, err)
+		}
+	}
+	return x, nil
+}
+
+func (x *xof) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(x.buf) == 0 {
+			if x.err == nil {
+				x.err = x.squeeze()
+			}
+			if x.err != nil {
+				return n, x.err
+			}
+		}
+		c := copy(p[n:], x.buf)
+		x.buf = x.buf[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// squeeze advances the trajectory and refills buf with the next block.
+func (x *xof) squeeze() error {
+	traj := make([]byte, 0, xofStepsPerBlock*len(x.state))
+	for i := 0; i < xofStepsPerBlock; i++ {
+		if err := integrateStep(Euler{}, x.state, x.f, xofDt); err != nil {
+			return fmt.Errorf("block %d: %w", x.counter, err)
+		}
+		for _, v := range x.state {
+			b, err := discretizeWithShift(v, 0)
+			if err != nil {
+				return fmt.Errorf("block %d: %w", x.counter, err)
+			}
+			traj = append(traj, b)
+		}
+	}
+
+	block := sha512.New()
+	block.Write(x.seed)
+	block.Write(binary.BigEndian.AppendUint64(nil, x.counter))
+	block.Write(traj)
+	x.buf = block.Sum(nil)
+	x.counter++
+	return nil
+}
@@ -0,0 +1,75 @@
+// =======================
+// qhash/xof_test.go
+// =======================
+
+package qhash
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func readXOF(t *testing.T, data []byte, n int, opts ...Option) []byte {
+	t.Helper()
+	r, err := NewXOF(data, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]byte, n)
+	if _, err := io.ReadFull(r, out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestXOFEmptyInput(t *testing.T) {
+	empty := readXOF(t, nil, 100)
+	if !bytes.Equal(empty, readXOF(t, []byte{}, 100)) {
+		t.Error("nil and empty input give different streams")
+	}
+	if bytes.Equal(empty, readXOF(t, []byte{0}, 100)) {
+		t.Error("empty input and a zero byte give the same stream")
+	}
+	if bytes.Equal(empty, readXOF(t, nil, 100, WithKey([]byte("key")))) {
+		t.Error("key does not change the empty stream")
+	}
+}
+
+func TestXOFSeedIsDigest(t *testing.T) {
+	// The default hasher has a memory-hard phase and a minimum compute
+	// time; the seed must not depend on either.
+	h, err := NewHardenedLorenzHasher(XOFHashSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := h.NewXOF([]byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := h.NewDigest()
+	d.Write([]byte("data"))
+	if seed := r.(*xof).seed; !bytes.Equal(seed, d.Sum(nil)) {
+		t.Errorf("seed %x, digest %x", seed, d.Sum(nil))
+	}
+}
+
+func TestXOFReadSizes(t *testing.T) {
+	want := readXOF(t, []byte("data"), 200)
+
+	r, err := NewXOF([]byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []byte
+	for _, n := range []int{1, 63, 1, 64, 71} {
+		p := make([]byte, n)
+		if _, err := io.ReadFull(r, p); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, p...)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("stream depends on read sizes")
+	}
+}
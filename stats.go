@@ -0,0 +1,129 @@
+// stats.go
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"chaos/v2/qhash"
+	"chaos/v2/qhash/stats"
+)
+
+// runStats runs the statistical test battery on the concatenated hashes of
+// the counters 0..n-1 (as 8-byte big-endian inputs), or on a file. Either
+// is held in memory, so both are limited to stats.MaxBits.
+func runStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	n := fs.Int("n", 1000, "Number of hashes")
+	size := fs.Int("size", 256, "Hash size: 256, 384, 512, or 1024 bits")
+	engine := fs.String("engine", qhash.EngineBigFloat, "Trajectory engine: bigfloat, fixed96, or float64")
+	memory := fs.Int("memory", qhash.DefaultMemoryHardness, "Memory-hard buffer size in KiB (0 disables)")
+	key := fs.String("key", "", "Key for keyed hashing")
+	workers := fs.Int("workers", 16, "Hashes computed concurrently")
+	file := fs.String("file", "", "Test the bytes of a file instead of hashes (- for stdin)")
+	fs.Parse(args)
+
+	var data []byte
+	if *file != "" {
+		r, err := openInput(*file, "")
+		if err != nil {
+			return err
+		}
+		data, err = io.ReadAll(io.LimitReader(r, stats.MaxBits/8+1))
+		r.Close()
+		if err != nil {
+			return err
+		}
+		if len(data) > stats.MaxBits/8 {
+			return fmt.Errorf("%s: longer than the limit of %d bytes", *file, stats.MaxBits/8)
+		}
+		fmt.Printf("%s: %d bits\n", *file, len(data)*8)
+	} else {
+		h, err := qhash.NewHardenedLorenzHasher(*size,
+			qhash.WithEngine(*engine),
+			qhash.WithMemoryHardness(*memory),
+			qhash.WithKey([]byte(*key)),
+			qhash.WithoutMinComputeTime(),
+		)
+		if err != nil {
+			return err
+		}
+		data, err = counterHashes(h, *n, *workers)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("QHASH-%d: %d hashes, %d bits\n", *size, *n, len(data)*8)
+	}
+
+	results, err := stats.Battery(data)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, r := range results {
+		p := make([]string, len(r.PValues))
+		for i, v := range r.PValues {
+			p[i] = fmt.Sprintf("%.6f", v)
+		}
+		status := "PASS"
+		if !r.Pass(stats.Alpha) {
+			status = "FAIL"
+			failed++
+		}
+		fmt.Printf("%-20s %-18s %s\n", r.Name, strings.Join(p, " "), status)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tests failed (alpha %g)", failed, len(results), stats.Alpha)
+	}
+	fmt.Printf("All %d tests passed (alpha %g)\n", len(results), stats.Alpha)
+	return nil
+}
+
+// counterHashes returns the hashes of the counters 0..n-1 concatenated in
+// counter order. Hashes are computed by up to workers goroutines.
+func counterHashes(h *qhash.HardenedLorenzHasher, n, workers int) ([]byte, error) {
+	size := h.GetHashSize() / 8
+	if n < 1 || n > stats.MaxBits/8/size {
+		return nil, fmt.Errorf("-n must be between 1 and %d for %d-bit hashes",
+			stats.MaxBits/8/size, size*8)
+	}
+	workers = max(1, min(workers, n))
+	out := make([]byte, n*size)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	next := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var counter [8]byte
+			for i := range next {
+				binary.BigEndian.PutUint64(counter[:], uint64(i))
+				sum, err := h.Hash(counter[:])
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = fmt.Errorf("hashing counter %d failed: %w", i, err)
+					}
+					mu.Unlock()
+					continue
+				}
+				copy(out[i*size:], sum)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return out, firstErr
+}